@@ -13,6 +13,8 @@ import (
 	http2 "github.com/physicist2018/gomodserial-v1/internal/delivery/http"
 	"github.com/physicist2018/gomodserial-v1/internal/delivery/serial"
 	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/database"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/parser"
+	infraserial "github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
 	"github.com/physicist2018/gomodserial-v1/internal/usecase"
 	"github.com/physicist2018/gomodserial-v1/pkg/config"
 )
@@ -20,12 +22,16 @@ import (
 const (
 	dbPath         = "data/experiments.db"
 	portName       = "/dev/random" // Change this to your actual COM port
-	baudRate       = 9600
 	templatesDir   = "./web/templates"
 	staticFilesDir = "./web/static"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		simulate(os.Args[2:])
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
@@ -41,12 +47,23 @@ func main() {
 	// Create use cases
 	experimentUC := usecase.NewExperimentUseCase(dbRepo)
 	measurementUC := usecase.NewMeasurementUseCase(dbRepo)
+	eventUC := usecase.NewEventUseCase(dbRepo)
+
+	// Check the parser and source settings of the ports before anything is started
+	for _, pc := range cfg.Ports {
+		if _, err := parser.New(pc.Parser, nil); err != nil {
+			log.Fatalf("Invalid parser settings of port %q: %v", pc.Name, err)
+		}
+		if _, err := infraserial.NewSource(pc); err != nil {
+			log.Fatalf("Invalid source of port %q: %v", pc.Name, err)
+		}
+	}
 
-	// Create serial listener
-	serialListener := serial.NewSerialListener(cfg.PortName, baudRate, measurementUC)
+	// Create serial listeners, one per port
+	portManager := serial.NewManager(cfg.Ports, experimentUC, measurementUC, eventUC)
 
 	// Create HTTP handler
-	webHandler := http2.NewWebHandler(experimentUC, measurementUC, serialListener, templatesDir)
+	webHandler := http2.NewWebHandler(experimentUC, measurementUC, eventUC, portManager, templatesDir)
 
 	// Set up HTTP server
 	mux := http.NewServeMux()
@@ -54,9 +71,14 @@ func main() {
 	mux.HandleFunc("/experiments/new", webHandler.NewExperiment)
 	mux.HandleFunc("/experiments", webHandler.ListExperiments)
 	mux.HandleFunc("/experiment", webHandler.ShowExperiment)
+	mux.HandleFunc("/experiment/quality", webHandler.ShowQuality)
+	mux.HandleFunc("/experiment/capture", webHandler.DownloadCapture)
 	// В функции main() после создания обработчиков:
 	mux.HandleFunc("/api/stop", webHandler.StopDataCollection)
 	mux.HandleFunc("/api/status", webHandler.DataCollectionStatus)
+	mux.HandleFunc("/api/parser/test", webHandler.TestParser)
+	mux.HandleFunc("/api/ports", webHandler.ListPorts)
+	mux.HandleFunc("/api/ports/", webHandler.PortAction)
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticFilesDir))))
 
 	server := &http.Server{
@@ -65,14 +87,32 @@ func main() {
 	}
 
 	// Context for graceful shutdown
-	// ctx, cancel := context.WithCancel(context.Background())
-	// defer cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Watch for serial devices being plugged in
+	if cfg.HotplugInterval > 0 {
+		go portManager.WatchHotplug(ctx, cfg.HotplugInterval)
+	}
 
 	// Start HTTP server
 	go func() {
 		log.Printf("Starting server on port %d", cfg.ServerPort)
 		log.Printf("Database path: %s", cfg.DBName)
-		log.Printf("COM port: %s (%d baud)", cfg.PortName, baudRate)
+		for _, pc := range cfg.Ports {
+			device := pc.Device
+			if !pc.Match.IsEmpty() {
+				device = fmt.Sprintf("USB %s", pc.Match)
+				if pc.Device != "" {
+					device += ", fallback " + pc.Device
+				}
+			}
+			if pc.Replay.Enabled() {
+				device = "replay of " + pc.Replay.File
+			}
+			log.Printf("COM port %s: %s (%d baud, %s, flow control: %s)",
+				pc.Name, device, pc.BaudRate, pc.LineSettings(), pc.FlowControl)
+		}
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP server error: %v", err)
 		}
@@ -84,7 +124,7 @@ func main() {
 	// Start serial listener when a new experiment is created
 	go func() {
 		for expID := range expChan {
-			if err := serialListener.Start(expID); err != nil {
+			if err := portManager.Start(cfg.Ports[0].Name, expID); err != nil {
 				log.Fatalf("Failed to start serial listener: %v", err)
 			}
 		}
@@ -106,6 +146,9 @@ func main() {
 
 	log.Println("Shutting down server...")
 
+	cancel()
+	portManager.StopAll()
+
 	// Graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
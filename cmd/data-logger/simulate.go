@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/simulator"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// simulate runs a synthetic device on a pseudo-terminal until interrupted.
+func simulate(args []string) {
+	cfg, err := config.LoadSimulator(os.Args[0]+" simulate", args)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load simulator config: %v", err)
+	}
+
+	sim, err := simulator.New(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to start simulator: %v", err)
+	}
+	defer sim.Close()
+	log.Printf("Simulated device at %s: %s", sim.Path(), sim.Describe())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := sim.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Simulator stopped: %v", err)
+	}
+}
@@ -2,34 +2,45 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/physicist2018/gomodserial-v1/internal/delivery/serial"
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/capture"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/parser"
 	"github.com/physicist2018/gomodserial-v1/internal/usecase"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
 )
 
 type WebHandler struct {
-	experimentUC   *usecase.ExperimentUseCase
-	measurementUC  *usecase.MeasurementUseCase
-	serialListener *serial.SerialListener
-	templateDir    string
+	experimentUC  *usecase.ExperimentUseCase
+	measurementUC *usecase.MeasurementUseCase
+	eventUC       *usecase.EventUseCase
+	ports         *serial.Manager
+	templateDir   string
 }
 
 func NewWebHandler(
 	experimentUC *usecase.ExperimentUseCase,
 	measurementUC *usecase.MeasurementUseCase,
-	serialListener *serial.SerialListener,
+	eventUC *usecase.EventUseCase,
+	ports *serial.Manager,
 	templateDir string,
 ) *WebHandler {
 	return &WebHandler{
-		experimentUC:   experimentUC,
-		measurementUC:  measurementUC,
-		serialListener: serialListener,
-		templateDir:    templateDir,
+		experimentUC:  experimentUC,
+		measurementUC: measurementUC,
+		eventUC:       eventUC,
+		ports:         ports,
+		templateDir:   templateDir,
 	}
 }
 
@@ -39,9 +50,14 @@ func (h *WebHandler) StopDataCollection(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.serialListener.Stop(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Без параметра port останавливаются все порты
+	if port := r.FormValue("port"); port != "" {
+		if err := h.ports.Stop(port); err != nil {
+			writePortError(w, err)
+			return
+		}
+	} else {
+		h.ports.StopAll()
 	}
 
 	// Возвращаем JSON ответ
@@ -53,22 +69,18 @@ func (h *WebHandler) StopDataCollection(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *WebHandler) DataCollectionStatus(w http.ResponseWriter, r *http.Request) {
-	status := h.serialListener.Status()
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"ports": h.ports.Status(),
+	})
 }
 
 // Обновляем NewExperiment для использования нового метода Start
 func (h *WebHandler) NewExperiment(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		currentExpID := 0
-		if h.serialListener.IsRunning() {
-			currentExpID = h.serialListener.CurrentExperimentID()
-		}
-
 		data := map[string]interface{}{
-			"CurrentExperimentID": currentExpID,
+			"ports":   h.ports.Status(),
+			"parsers": selectableParsers(),
 		}
 
 		err := h.renderTemplate(w, "new_experiment.html", pongo2.Context(data))
@@ -96,15 +108,54 @@ func (h *WebHandler) NewExperiment(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		experiment, err := h.experimentUC.CreateExperiment(r.Context(), name, description)
+		port := r.FormValue("port")
+		if port == "" && len(h.ports.Names()) == 1 {
+			port = h.ports.Names()[0]
+		}
+		sl, err := h.ports.Listener(port)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sl.IsRunning() {
+			http.Error(w, fmt.Sprintf("Port %s is already collecting data for experiment %d",
+				port, sl.CurrentExperimentID()), http.StatusConflict)
+			return
+		}
+
+		draft := entity.Experiment{
+			Name:        name,
+			Description: description,
+			Port:        port,
+			Channels:    channelsFromForm(r),
+			Parser:      parserSpecFromForm(r),
+		}
+		if draft.Parser.Type != "" {
+			if _, err := parser.New(parserConfig(draft.Parser), draft.Channels); err != nil {
+				http.Error(w, "Invalid parser: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		experiment, err := h.experimentUC.CreateExperiment(r.Context(), draft)
 		if err != nil {
+			if errors.Is(err, usecase.ErrInvalidInput) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Запускаем сбор данных для нового эксперимента
-		if err := h.serialListener.Start(experiment.ID); err != nil {
-			http.Error(w, "Failed to start data collection", http.StatusInternalServerError)
+		// Запускаем сбор данных для нового эксперимента. Порт мог занять
+		// другой запрос после проверки выше; тогда эксперимент без данных
+		// не нужен
+		if err := h.ports.Start(port, experiment.ID); err != nil {
+			log.Printf("Failed to start data collection: %v", err)
+			if err := h.experimentUC.DeleteExperiment(r.Context(), experiment.ID); err != nil {
+				log.Printf("Failed to delete experiment %d: %v", experiment.ID, err)
+			}
+			writePortError(w, err)
 			return
 		}
 
@@ -112,6 +163,108 @@ func (h *WebHandler) NewExperiment(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// channelsFromForm reads the channel rows of the new experiment form. Rows
+// without a name are ignored.
+func channelsFromForm(r *http.Request) []entity.Channel {
+	names := r.Form["channel_name"]
+	units := r.Form["channel_unit"]
+	types := r.Form["channel_type"]
+
+	var channels []entity.Channel
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		ch := entity.Channel{Name: name}
+		if i < len(units) {
+			ch.Unit = units[i]
+		}
+		if i < len(types) {
+			ch.Type = entity.ChannelType(types[i])
+		}
+		channels = append(channels, ch)
+	}
+	return channels
+}
+
+func parserSpecFromForm(r *http.Request) entity.ParserSpec {
+	return entity.ParserSpec{
+		Type:      r.FormValue("parser_type"),
+		Separator: r.FormValue("parser_separator"),
+		Pattern:   r.FormValue("parser_pattern"),
+	}
+}
+
+func parserConfig(spec entity.ParserSpec) config.ParserConfig {
+	return config.ParserConfig{
+		Type:      spec.Type,
+		Separator: spec.Separator,
+		Pattern:   spec.Pattern,
+	}
+}
+
+// selectableParsers lists the parsers an experiment can choose. The binary
+// parser depends on the layout of the port and is selected there.
+func selectableParsers() []string {
+	var names []string
+	for _, name := range parser.Names() {
+		if name != config.ParserBinary {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type parserTestResult struct {
+	Line   string                `json:"line"`
+	Values []entity.ChannelValue `json:"values"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// TestParser runs the parser and channels from the new experiment form
+// against the pasted sample, one line at a time.
+func (h *WebHandler) TestParser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	spec := parserSpecFromForm(r)
+	if spec.Type == "" {
+		spec.Type = config.ParserAuto
+	}
+	p, err := parser.New(parserConfig(spec), channelsFromForm(r))
+	if err != nil {
+		http.Error(w, "Invalid parser: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := []parserTestResult{}
+	for _, line := range strings.Split(r.FormValue("sample"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		result := parserTestResult{Line: line}
+		values, err := p.Parse([]byte(line))
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Values = values
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"results": results,
+	})
+}
+
 // func (h *WebHandler) NewExperiment(w http.ResponseWriter, r *http.Request) {
 // 	if r.Method == http.MethodGet {
 // 		currentExpID := 0
@@ -218,6 +371,42 @@ func (h *WebHandler) ShowExperiment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events, err := h.eventUC.GetEventsByExperimentID(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to get events: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	sessions, err := h.experimentUC.GetStats(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to get session stats: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var captures []capture.File
+	for _, dir := range h.ports.CaptureDirs() {
+		files, err := capture.List(dir, id)
+		if err != nil {
+			log.Printf("Failed to list captures in %s: %v", dir, err)
+			continue
+		}
+		captures = append(captures, files...)
+	}
+
+	axis := r.URL.Query().Get("time")
+	if axis != axisDevice && axis != axisGPS {
+		axis = axisHost
+	}
+
+	quarantine, err := h.measurementUC.GetQuarantinedFrames(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to get quarantined frames: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	// data := struct {
 	// 	Experiment   *entity.Experiment
 	// 	Measurements []entity.Measurement
@@ -228,7 +417,13 @@ func (h *WebHandler) ShowExperiment(w http.ResponseWriter, r *http.Request) {
 
 	content := pongo2.Context{
 		"experiment":   experiment,
+		"collecting":   h.ports.ExperimentPort(id) != "",
 		"measurements": measurements,
+		"events":       events,
+		"sessions":     sessions,
+		"quarantine":   quarantine,
+		"captures":     captures,
+		"table":        newMeasurementTable(experiment.Channels, measurements, axis),
 	}
 
 	err = h.renderTemplate(w, "experiment.html", content)
@@ -237,6 +432,82 @@ func (h *WebHandler) ShowExperiment(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DownloadCapture sends a raw capture file of an experiment.
+func (h *WebHandler) DownloadCapture(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid experiment ID", http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("file")
+	if !capture.IsCaptureFile(name, id) {
+		http.Error(w, "Invalid capture file", http.StatusBadRequest)
+		return
+	}
+
+	for _, dir := range h.ports.CaptureDirs() {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeFile(w, r, path)
+		return
+	}
+	http.Error(w, "Not Found", http.StatusNotFound)
+}
+
+// ShowQuality reports the gaps, duplicates and late records in the record
+// counter of an experiment, as a page or, with format=json, as JSON.
+func (h *WebHandler) ShowQuality(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid experiment ID", http.StatusBadRequest)
+		return
+	}
+
+	experiment, err := h.experimentUC.GetExperimentByID(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to get experiment: %v", err)
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	// Счётчик мог быть настроен только у порта эксперимента
+	var sequence config.SequenceConfig
+	if sl, err := h.ports.Listener(experiment.Port); err == nil {
+		sequence = sl.Config().Sequence
+	}
+
+	report, err := h.measurementUC.QualityReport(r.Context(), id, sequence.Modulus)
+	if err != nil {
+		log.Printf("Failed to build quality report: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"experiment_id": id,
+			"sequence":      sequence,
+			"completeness":  report.Completeness(),
+			"report":        report,
+		})
+		return
+	}
+
+	err = h.renderTemplate(w, "quality.html", pongo2.Context{
+		"experiment": experiment,
+		"sequence":   sequence,
+		"report":     report,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (h *WebHandler) Home(w http.ResponseWriter, r *http.Request) {
 	log.Println("HOME")
 	http.Redirect(w, r, "/experiments", http.StatusSeeOther)
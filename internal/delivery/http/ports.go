@@ -0,0 +1,151 @@
+package http
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/delivery/serial"
+	infraserial "github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
+)
+
+// ListPorts handles /api/ports: the serial devices currently present on
+// the system, with USB identifiers where available.
+func (h *WebHandler) ListPorts(w http.ResponseWriter, r *http.Request) {
+	ports, err := infraserial.ListPorts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ports == nil {
+		ports = []infraserial.PortInfo{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"ports": ports,
+	})
+}
+
+// PortAction handles /api/ports/{name}/start, /stop, /device and /send.
+// Start resumes collection for the experiment given in experiment_id,
+// device assigns the device path given in device, send writes command to
+// the device and waits up to wait (e.g. "2s") for the response.
+func (h *WebHandler) PortAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/ports/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	port, action := parts[0], parts[1]
+
+	var message string
+	switch action {
+	case "start":
+		experimentID, err := strconv.Atoi(r.FormValue("experiment_id"))
+		if err != nil {
+			http.Error(w, "Invalid experiment ID", http.StatusBadRequest)
+			return
+		}
+		if _, err := h.experimentUC.GetExperimentByID(r.Context(), experimentID); err != nil {
+			http.Error(w, "Experiment not found", http.StatusNotFound)
+			return
+		}
+		if err := h.ports.Start(port, experimentID); err != nil {
+			writePortError(w, err)
+			return
+		}
+		message = "Data collection started"
+	case "stop":
+		if err := h.ports.Stop(port); err != nil {
+			writePortError(w, err)
+			return
+		}
+		message = "Data collection stopped"
+	case "device":
+		device := r.FormValue("device")
+		if device == "" {
+			http.Error(w, "Device is required", http.StatusBadRequest)
+			return
+		}
+		if err := h.ports.SetDevice(port, device); err != nil {
+			writePortError(w, err)
+			return
+		}
+		message = "Device changed"
+	case "send":
+		h.sendCommand(w, r, port)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": message,
+	})
+}
+
+func (h *WebHandler) sendCommand(w http.ResponseWriter, r *http.Request, port string) {
+	command := r.FormValue("command")
+	if command == "" {
+		http.Error(w, "Command is required", http.StatusBadRequest)
+		return
+	}
+	var wait time.Duration
+	if v := r.FormValue("wait"); v != "" {
+		var err error
+		if wait, err = time.ParseDuration(v); err != nil || wait < 0 || wait > maxCommandWait {
+			http.Error(w, "Invalid wait duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sl, err := h.ports.Listener(port)
+	if err != nil {
+		writePortError(w, err)
+		return
+	}
+	response, err := sl.SendCommand(r.Context(), command, wait)
+	if err != nil {
+		writePortError(w, err)
+		return
+	}
+
+	result := map[string]any{
+		"status":  "success",
+		"message": "Command sent",
+	}
+	if response != nil {
+		result["response"] = string(response)
+		result["response_hex"] = hex.EncodeToString(response)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+const maxCommandWait = time.Minute
+
+func writePortError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, serial.ErrUnknownPort):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, serial.ErrPortBusy), errors.Is(err, infraserial.ErrNotConnected):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, serial.ErrNoResponse):
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
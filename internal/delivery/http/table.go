@@ -0,0 +1,95 @@
+package http
+
+import (
+	"sort"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+)
+
+// Time axes of the measurement table.
+const (
+	axisHost   = "host"   // time the frame was received
+	axisDevice = "device" // time the device gave the measurement
+	axisGPS    = "gps"    // time the frame was received, by the GPS clock
+)
+
+// measurementTable is the experiment page view of the measurements: one
+// column per channel.
+type measurementTable struct {
+	Axis    string
+	Columns []string
+	Rows    []measurementRow
+}
+
+type measurementRow struct {
+	Measurement entity.Measurement
+	Time        string // on the chosen axis, empty if the measurement has none
+	Cells       []string
+}
+
+const tableTimeFormat = "2006-01-02 15:04:05.000"
+
+// newMeasurementTable builds the table from the declared channels. For
+// experiments without a schema the columns are taken from the decoded
+// values themselves, in order of appearance. Rows are ordered on the time
+// axis; measurements without a time on the axis come last.
+func newMeasurementTable(channels []entity.Channel, measurements []entity.Measurement, axis string) measurementTable {
+	var names []string
+	table := measurementTable{Axis: axis}
+
+	if len(channels) > 0 {
+		for _, ch := range channels {
+			names = append(names, ch.Name)
+			table.Columns = append(table.Columns, ch.Title())
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, m := range measurements {
+			for _, v := range m.Values {
+				if !seen[v.Channel] {
+					seen[v.Channel] = true
+					names = append(names, v.Channel)
+				}
+			}
+		}
+		table.Columns = names
+	}
+
+	if axis != axisHost {
+		measurements = append([]entity.Measurement(nil), measurements...)
+		sort.SliceStable(measurements, func(i, j int) bool {
+			a, b := axisTime(measurements[i], axis), axisTime(measurements[j], axis)
+			if a == nil || b == nil {
+				return a != nil && b == nil
+			}
+			return a.Before(*b)
+		})
+	}
+
+	for _, m := range measurements {
+		row := measurementRow{Measurement: m, Cells: make([]string, len(names))}
+		if t := axisTime(m, axis); t != nil {
+			row.Time = t.Format(tableTimeFormat)
+		}
+		for i, name := range names {
+			if v, ok := m.ValueOf(name); ok {
+				row.Cells[i] = v.String()
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// axisTime returns the time of the measurement on the axis, nil if it has
+// none.
+func axisTime(m entity.Measurement, axis string) *time.Time {
+	switch axis {
+	case axisDevice:
+		return m.DeviceTime
+	case axisGPS:
+		return m.GPSTime
+	}
+	return &m.Timestamp
+}
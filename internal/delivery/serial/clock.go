@@ -0,0 +1,48 @@
+package serial
+
+import (
+	"sync"
+	"time"
+)
+
+// gpsClock follows the offset between GPS time and the host clock, taken
+// each time the GPS port receives a sentence with a fix. Ports that use
+// the GPS port as their time source stamp measurements with host time
+// corrected by that offset.
+type gpsClock struct {
+	mu      sync.Mutex
+	offset  time.Duration
+	updated time.Time
+}
+
+func (c *gpsClock) update(gps, received time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = gps.Sub(received)
+	c.updated = received
+}
+
+// now converts a host time to GPS time. It reports false until the GPS
+// port has received a fix; after that the last offset is used even while
+// the fix is lost.
+func (c *gpsClock) now(host time.Time) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.updated.IsZero() {
+		return host, false
+	}
+	return host.Add(c.offset).UTC(), true
+}
+
+func (c *gpsClock) status() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.updated.IsZero() {
+		return map[string]any{"synchronized": false}
+	}
+	return map[string]any{
+		"synchronized": true,
+		"offset_ms":    c.offset.Milliseconds(),
+		"updated":      c.updated,
+	}
+}
@@ -1,31 +1,72 @@
 package serial
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
-
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/capture"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/charset"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/checksum"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/parser"
 	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/share"
 	"github.com/physicist2018/gomodserial-v1/internal/usecase"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
 )
 
+var ErrPortBusy = errors.New("port is already in use")
+
 type SerialListener struct {
-	portListener  *serial.PortListener
+	name          string
+	cfg           config.PortConfig
+	source        serial.DataSource // source of the running session
+	experimentUC  *usecase.ExperimentUseCase
 	measurementUC *usecase.MeasurementUseCase
+	eventUC       *usecase.EventUseCase
 	currentExpID  int
 	mu            sync.Mutex
 	stopChan      chan struct{}
 	isRunning     bool
+	sendMu        sync.Mutex
+	waiters       []chan []byte // frames awaited as responses to commands
+	instrument    string        // identity of the SCPI instrument, once it has answered
+	stalledSince  time.Time     // set while the watchdog finds the device silent
+
+	validFrames   atomic.Uint64 // frames of the session that passed the checksum
+	invalidFrames atomic.Uint64 // frames of the session sent to quarantine
+	seqMissing    atomic.Int64  // records the device counter shows as lost
+	seqDuplicates atomic.Int64
+	seqLate       atomic.Int64 // records that arrived out of order
+	stats         sessionStats
 
 	cancelFunc context.CancelFunc
 	ctx        context.Context
+	done       chan struct{} // closed when the session has finished
+
+	share *share.Server // serves the raw stream to TCP clients, once started
+
+	clock      *gpsClock // set on a GPS port other ports take their time from
+	timeSource *gpsClock // set on ports stamped with GPS time
 }
 
-func NewSerialListener(portName string, baudRate int, measurementUC *usecase.MeasurementUseCase) *SerialListener {
+func NewSerialListener(portCfg config.PortConfig, experimentUC *usecase.ExperimentUseCase, measurementUC *usecase.MeasurementUseCase, eventUC *usecase.EventUseCase) *SerialListener {
 	return &SerialListener{
-		portListener:  serial.NewPortListener(portName, baudRate),
+		name:          portCfg.Name,
+		cfg:           portCfg,
+		experimentUC:  experimentUC,
 		measurementUC: measurementUC,
+		eventUC:       eventUC,
 		stopChan:      make(chan struct{}),
 	}
 }
@@ -92,12 +133,19 @@ func (sl *SerialListener) Start(experimentID int) error {
 	sl.mu.Lock()
 	defer sl.mu.Unlock()
 
-	// Останавливаем предыдущий сбор данных, если запущен
+	// Порт может обслуживать только один эксперимент
 	if sl.isRunning {
-		sl.stop()
+		return fmt.Errorf("%w: port %s is collecting data for experiment %d",
+			ErrPortBusy, sl.Name(), sl.currentExpID)
 	}
 
 	sl.currentExpID = experimentID
+	sl.validFrames.Store(0)
+	sl.invalidFrames.Store(0)
+	sl.seqMissing.Store(0)
+	sl.seqDuplicates.Store(0)
+	sl.seqLate.Store(0)
+	sl.stats.reset(time.Now())
 
 	// Создаем контекст с возможностью отмены
 	ctx, cancel := context.WithCancel(context.Background())
@@ -105,26 +153,43 @@ func (sl *SerialListener) Start(experimentID int) error {
 	sl.cancelFunc = cancel
 
 	// Запускаем сбор данных в отдельной горутине
-	go sl.collectData(ctx)
+	done := make(chan struct{})
+	sl.done = done
+	portCfg := sl.cfg
+	go func() {
+		defer close(done)
+		sl.collectData(ctx, experimentID, portCfg)
+	}()
 
 	sl.isRunning = true
-	log.Printf("Started data collection for experiment %d", experimentID)
+	log.Printf("Started data collection for experiment %d on port %s", experimentID, sl.Name())
 	return nil
 }
 
+// Stop ends the session and waits until it has closed the port and
+// recorded its last events.
 func (sl *SerialListener) Stop() error {
 	sl.mu.Lock()
-	defer sl.mu.Unlock()
-
 	if !sl.isRunning {
+		sl.mu.Unlock()
 		return nil
 	}
 
+	log.Printf("Stopped data collection for experiment %d on port %s", sl.currentExpID, sl.Name())
 	sl.stop()
-	log.Printf("Stopped data collection for experiment %d", sl.currentExpID)
+	done := sl.done
+	sl.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		log.Printf("Port %s: data collection did not finish within %s", sl.Name(), stopTimeout)
+	}
 	return nil
 }
 
+const stopTimeout = 5 * time.Second
+
 func (sl *SerialListener) stop() {
 	if sl.cancelFunc != nil {
 		sl.cancelFunc()
@@ -135,35 +200,142 @@ func (sl *SerialListener) stop() {
 	sl.currentExpID = 0
 }
 
-func (sl *SerialListener) collectData(ctx context.Context) {
-	// Инициализация порта
-	port := serial.NewPortListener(sl.portListener.Name(), sl.portListener.BaudRate())
-	if err := port.Open(); err != nil {
-		log.Printf("Failed to open port: %v", err)
+// finish marks the session as stopped when collection ends on its own,
+// e.g. because the port could not be opened.
+func (sl *SerialListener) finish(ctx context.Context) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if sl.isRunning && sl.ctx == ctx {
+		sl.stop()
+	}
+}
+
+func (sl *SerialListener) collectData(ctx context.Context, experimentID int, portCfg config.PortConfig) {
+	defer sl.finish(ctx)
+
+	p, err := sl.newParser(ctx, experimentID, portCfg)
+	if err != nil {
+		log.Printf("Failed to set up parser: %v", err)
 		return
 	}
+
+	// Порт открывается в Listen, потерянный порт переоткрывается там же
+	port, err := serial.NewSource(portCfg)
+	if err != nil {
+		log.Printf("Port %s: %v", sl.name, err)
+		return
+	}
+	defer sl.saveStats(experimentID, port)
+	poller, canPoll := port.(serial.Poller)
+	if !canPoll && (portCfg.SCPI.Enabled() || portCfg.Modbus.Enabled() || portCfg.Poll.Enabled()) {
+		log.Printf("Port %s: source %s cannot poll the device", sl.name, portCfg.Source)
+		return
+	}
+	port.OnStateChange(sl.gapRecorder(experimentID))
+	capturer, canCapture := port.(serial.Capturer)
+	if (portCfg.Capture.Enabled() || portCfg.Share.Enabled()) && !canCapture {
+		log.Printf("Port %s: source %s cannot tee the raw stream, capture and sharing disabled", sl.name, portCfg.Source)
+	} else {
+		if portCfg.Capture.Enabled() {
+			if w, err := capture.NewWriter(portCfg.Capture, experimentID, sl.name); err != nil {
+				log.Printf("Port %s: raw capture disabled: %v", sl.name, err)
+			} else {
+				defer w.Close()
+				capturer.Capture(w)
+			}
+		}
+		if srv := sl.shareServer(portCfg); srv != nil {
+			capturer.Capture(srv)
+			srv.SetTarget(func(data []byte) error {
+				ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+				defer cancel()
+				return port.Write(ctx, data)
+			})
+			defer srv.SetTarget(nil)
+		}
+	}
 	defer port.Close()
 
-	dataChan := make(chan string)
-	errorChan := make(chan error)
+	sl.mu.Lock()
+	sl.source = port
+	sl.mu.Unlock()
+
+	dataChan := make(chan serial.Frame)
+	errorChan := make(chan error, 1)
+	timeoutChan := make(chan serial.PollTimeout)
+	noticeChan := make(chan serial.Notice)
+
+	// Запускаем прослушивание порта или опрос прибора
+	switch {
+	case portCfg.SCPI.Enabled():
+		go poller.PollSCPI(ctx, dataChan, timeoutChan, noticeChan, errorChan)
+	case portCfg.Modbus.Enabled():
+		go poller.PollModbus(ctx, dataChan, timeoutChan, errorChan)
+	case portCfg.Poll.Enabled():
+		go poller.Poll(ctx, dataChan, timeoutChan, errorChan)
+	default:
+		go port.Listen(ctx, dataChan, errorChan)
+	}
 
-	// Запускаем прослушивание порта
-	go port.Listen(ctx, dataChan, errorChan)
+	gap := &timeoutGap{}
+	defer sl.recordTimeoutGap(experimentID, gap)
+	seq := &entity.SequenceTracker{Modulus: portCfg.Sequence.Modulus}
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	var wd *watchdog
+	if portCfg.Watchdog.Enabled() {
+		wd = newWatchdog(portCfg.Watchdog, time.Now())
+		defer wd.stop()
+		defer sl.setStalled(time.Time{})
+	}
 
 	for {
 		select {
-		case data := <-dataChan:
-			lines := strings.Split(data, "\n")
-			for _, line := range lines {
-				line = strings.TrimSpace(line)
-				if line != "" {
-					if err := sl.measurementUC.CreateMeasurement(ctx, sl.currentExpID, line); err != nil {
-						log.Printf("Failed to save measurement: %v", err)
-					} else {
-						log.Printf("Saved measurement to experiment %d: %s", sl.currentExpID, line)
-					}
+		case now := <-ticker.C:
+			sl.stats.sample(now, port.Status().BytesRead)
+		case now := <-wd.C():
+			sl.watchdogFired(ctx, experimentID, portCfg, port, wd, now)
+		case t := <-timeoutChan:
+			gap.add(t)
+		case n := <-noticeChan:
+			n.Message = string(charset.Decode(portCfg.Encoding, []byte(n.Message)))
+			sl.recordNotice(ctx, experimentID, n)
+		case f := <-dataChan:
+			sl.recordTimeoutGap(experimentID, gap)
+			frame, received := f.Data, f.ReceivedAt
+			sl.stats.frame(received)
+			sl.watchdogFrame(experimentID, wd, received)
+			sl.notifyWaiters(charset.Decode(portCfg.Encoding, frame))
+			payload := sl.verify(ctx, experimentID, portCfg, frame, received)
+			if payload == nil {
+				continue
+			}
+			// Контрольная сумма считается по исходным байтам, разбирается уже UTF-8
+			payload = charset.Decode(portCfg.Encoding, payload)
+			m := newMeasurement(experimentID, payload, p)
+			if m == nil {
+				continue
+			}
+			m.Raw = frame
+			sl.stamp(m, received)
+			if portCfg.DeviceTime.Enabled() && m.ParseError == "" {
+				if t, err := parser.DeviceTime(portCfg.DeviceTime, m.Values, received); err != nil {
+					log.Printf("Port %s: no device time in %q: %v", sl.name, frame, err)
+				} else {
+					m.DeviceTime = &t
 				}
 			}
+			if portCfg.Sequence.Enabled() && m.ParseError == "" {
+				sl.checkSequence(experimentID, portCfg.Sequence, seq, m)
+			}
+			sl.stats.measurement(m)
+			if err := sl.measurementUC.CreateMeasurement(ctx, m); err != nil {
+				sl.stats.dbError()
+				log.Printf("Failed to save measurement: %v", err)
+			} else {
+				log.Printf("Saved measurement to experiment %d: %s", m.ExperimentID, m.Value)
+			}
 		case err := <-errorChan:
 			log.Printf("Serial port error: %v", err)
 			return
@@ -177,6 +349,485 @@ func (sl *SerialListener) collectData(ctx context.Context) {
 	}
 }
 
+// saveStats records the summary of the session with the experiment.
+func (sl *SerialListener) saveStats(experimentID int, port serial.DataSource) {
+	src := port.Status()
+	stats := sl.stats.summary(time.Now(), src.BytesRead)
+	stats.ExperimentID = experimentID
+	stats.Port = sl.name
+	stats.FrameErrors = int64(src.FrameErrors)
+	stats.Quarantined = int64(sl.invalidFrames.Load())
+	if err := sl.experimentUC.SaveStats(context.Background(), &stats); err != nil {
+		log.Printf("Failed to save stats of experiment %d: %v", experimentID, err)
+		return
+	}
+	log.Printf("Port %s: experiment %d read %d bytes in %d frames (%d parsed, %d parse errors, %d not saved), %d reconnects",
+		sl.name, experimentID, stats.BytesRead, stats.Frames, stats.Parsed, stats.ParseErrors, stats.DBErrors, stats.Reconnects)
+}
+
+// shareServer returns the server sharing the port, starting it on first
+// use. It outlives the session, so clients stay connected between
+// experiments.
+func (sl *SerialListener) shareServer(portCfg config.PortConfig) *share.Server {
+	if !portCfg.Share.Enabled() {
+		return nil
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.share == nil {
+		srv, err := share.Listen(portCfg.Share, sl.name)
+		if err != nil {
+			log.Printf("Port %s: sharing disabled: %v", sl.name, err)
+			return nil
+		}
+		sl.share = srv
+	}
+	return sl.share
+}
+
+// verify checks the checksum of the frame, if the port has one. A frame
+// that fails is quarantined and nil is returned; otherwise the frame is
+// returned without its checksum. The nmea parser checks the checksum itself
+// and gets the whole sentence.
+func (sl *SerialListener) verify(ctx context.Context, experimentID int, portCfg config.PortConfig, frame []byte, received time.Time) []byte {
+	if !portCfg.Checksum.Enabled() || len(bytes.TrimSpace(frame)) == 0 {
+		return frame
+	}
+
+	payload, err := checksum.Verify(portCfg.Checksum, frame)
+	if err != nil {
+		sl.invalidFrames.Add(1)
+		log.Printf("Port %s: quarantined frame %q: %v", sl.name, frame, err)
+		q := &entity.QuarantinedFrame{
+			ExperimentID: experimentID,
+			Port:         sl.name,
+			Raw:          frame,
+			Reason:       err.Error(),
+			ReceivedAt:   received,
+		}
+		if err := sl.measurementUC.QuarantineFrame(ctx, q); err != nil {
+			log.Printf("Failed to quarantine frame: %v", err)
+		}
+		return nil
+	}
+
+	sl.validFrames.Add(1)
+	if portCfg.Parser.Type == config.ParserNMEA {
+		return frame
+	}
+	return payload
+}
+
+// checkSequence takes the record counter out of the measurement and
+// records the gaps, duplicates and late records it reveals.
+func (sl *SerialListener) checkSequence(experimentID int, cfg config.SequenceConfig, tracker *entity.SequenceTracker, m *entity.Measurement) {
+	n, err := parser.Sequence(cfg.Channel, m.Values)
+	if err != nil {
+		log.Printf("Port %s: no sequence number in %q: %v", sl.name, m.Value, err)
+		return
+	}
+	m.Sequence = &n
+
+	issue := tracker.Next(n, m.Timestamp)
+	if issue == nil {
+		return
+	}
+	event := &entity.Event{
+		ExperimentID: experimentID,
+		Kind:         issue.Kind,
+		StartedAt:    issue.To,
+	}
+	switch issue.Kind {
+	case entity.EventSequenceGap:
+		sl.seqMissing.Add(issue.Missing)
+		event.Message = fmt.Sprintf("%d records missing: expected %d, got %d", issue.Missing, issue.Expected, issue.Got)
+		event.StartedAt, event.EndedAt = issue.From, issue.To
+	case entity.EventDuplicate:
+		sl.seqDuplicates.Add(1)
+		event.Message = fmt.Sprintf("record %d received again", issue.Got)
+	case entity.EventOutOfOrder:
+		sl.seqLate.Add(1)
+		event.Message = fmt.Sprintf("record %d arrived late, expected %d", issue.Got, issue.Expected)
+		if issue.Filled {
+			sl.seqMissing.Add(-1)
+			event.Message += "; it was counted as missing"
+		}
+	case entity.EventCounterReset:
+		event.Message = fmt.Sprintf("counter started over at %d, expected %d", issue.Got, issue.Expected)
+	}
+	log.Printf("Port %s: %s", sl.name, event.Message)
+	sl.recordEvent(event)
+}
+
+// stamp sets the measurement time to the time the frame was received and,
+// if the port has a GPS clock, the GPS time of that moment. A GPS port also
+// keeps its clock up to date from its own sentences.
+func (sl *SerialListener) stamp(m *entity.Measurement, received time.Time) {
+	m.Timestamp = received
+	clock := sl.timeSource
+	if sl.clock != nil {
+		// GGA carries no date: take it from the clock once it is set
+		ref, _ := sl.clock.now(received)
+		if t, ok := parser.GPSTime(m.Values, ref); ok {
+			sl.clock.update(t, received)
+		}
+		clock = sl.clock
+	}
+	if clock == nil {
+		return
+	}
+	if t, ok := clock.now(received); ok {
+		m.GPSTime = &t
+	}
+}
+
+// timeoutGap collects consecutive unanswered polls into one gap.
+type timeoutGap struct {
+	query  string
+	reason string
+	start  time.Time
+	end    time.Time
+	count  int
+}
+
+func (g *timeoutGap) add(t serial.PollTimeout) {
+	if g.count == 0 {
+		g.query = t.Query
+		g.start = t.SentAt
+	}
+	g.end = t.Until
+	g.reason = t.Reason
+	g.count++
+}
+
+// recordTimeoutGap records the gap, if any, as an event and resets it.
+func (sl *SerialListener) recordTimeoutGap(experimentID int, g *timeoutGap) {
+	if g.count == 0 {
+		return
+	}
+	sl.recordEvent(&entity.Event{
+		ExperimentID: experimentID,
+		Kind:         entity.EventTimeout,
+		Message:      fmt.Sprintf("%d polls failed, last: %s: %s", g.count, g.query, g.reason),
+		StartedAt:    g.start,
+		EndedAt:      g.end,
+	})
+	*g = timeoutGap{}
+}
+
+// recordNotice records what the instrument reported in the experiment
+// log. Its identity is also kept with the experiment.
+func (sl *SerialListener) recordNotice(ctx context.Context, experimentID int, n serial.Notice) {
+	kind := entity.EventCommand
+	switch n.Kind {
+	case serial.NoticeIdentity:
+		kind = entity.EventIdentity
+		sl.mu.Lock()
+		sl.instrument = n.Message
+		sl.mu.Unlock()
+		if err := sl.experimentUC.SetInstrument(ctx, experimentID, n.Message); err != nil {
+			log.Printf("Failed to record instrument of experiment %d: %v", experimentID, err)
+		}
+	case serial.NoticeError:
+		kind = entity.EventInstrumentError
+		log.Printf("Port %s: instrument error: %s", sl.name, n.Message)
+	}
+	sl.recordEvent(&entity.Event{
+		ExperimentID: experimentID,
+		Kind:         kind,
+		Message:      n.Message,
+		StartedAt:    n.At,
+	})
+}
+
+// SendCommand sends a command with the port's command ending appended. If
+// wait is positive, the first frame received within wait is returned as
+// the response; it is still stored as a measurement. Both are recorded as
+// events of the running experiment. Commands on a port are sent one at a
+// time, so each waits for its own response.
+func (sl *SerialListener) SendCommand(ctx context.Context, command string, wait time.Duration) ([]byte, error) {
+	sl.sendMu.Lock()
+	defer sl.sendMu.Unlock()
+
+	sl.mu.Lock()
+	port, experimentID := sl.source, sl.currentExpID
+	running := sl.isRunning
+	sl.mu.Unlock()
+	if !running || port == nil {
+		return nil, fmt.Errorf("%w: port %s is not collecting data", serial.ErrNotConnected, sl.name)
+	}
+
+	var responses chan []byte
+	if wait > 0 {
+		responses = sl.addWaiter()
+		defer sl.removeWaiter(responses)
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	sentAt := time.Now()
+	if err := port.Write(writeCtx, []byte(command+port.Config().CommandEnding)); err != nil {
+		return nil, err
+	}
+	sl.recordEvent(&entity.Event{
+		ExperimentID: experimentID,
+		Kind:         entity.EventCommand,
+		Message:      command,
+		StartedAt:    sentAt,
+	})
+
+	if responses == nil {
+		return nil, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case frame := <-responses:
+		sl.recordEvent(&entity.Event{
+			ExperimentID: experimentID,
+			Kind:         entity.EventResponse,
+			Message:      formatFrame(frame),
+			StartedAt:    sentAt,
+			EndedAt:      time.Now(),
+		})
+		return frame, nil
+	case <-timer.C:
+		sl.recordEvent(&entity.Event{
+			ExperimentID: experimentID,
+			Kind:         entity.EventResponse,
+			Message:      fmt.Sprintf("no response to %q within %s", command, wait),
+			StartedAt:    sentAt,
+			EndedAt:      time.Now(),
+		})
+		return nil, fmt.Errorf("%w within %s", ErrNoResponse, wait)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sendTimeout limits how long a command waits for the remote side to
+// become ready to receive.
+const sendTimeout = 5 * time.Second
+
+var ErrNoResponse = errors.New("no response")
+
+func (sl *SerialListener) addWaiter() chan []byte {
+	ch := make(chan []byte, 1)
+	sl.mu.Lock()
+	sl.waiters = append(sl.waiters, ch)
+	sl.mu.Unlock()
+	return ch
+}
+
+func (sl *SerialListener) removeWaiter(ch chan []byte) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	for i, w := range sl.waiters {
+		if w == ch {
+			sl.waiters = append(sl.waiters[:i], sl.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyWaiters hands a received frame to the commands waiting for a
+// response. Each waiter gets only the first frame.
+func (sl *SerialListener) notifyWaiters(frame []byte) {
+	sl.mu.Lock()
+	waiters := sl.waiters
+	sl.waiters = nil
+	sl.mu.Unlock()
+
+	for _, w := range waiters {
+		select {
+		case w <- frame:
+		default:
+		}
+	}
+}
+
+// formatFrame returns the frame as text if it is printable, in hex
+// otherwise.
+func formatFrame(frame []byte) string {
+	if isText(frame) {
+		return strings.TrimSpace(string(frame))
+	}
+	return hex.EncodeToString(frame)
+}
+
+// gapRecorder returns a state handler that records the time the port was
+// lost as a disconnect event of the experiment.
+func (sl *SerialListener) gapRecorder(experimentID int) func(serial.StateChange) {
+	var (
+		mu     sync.Mutex
+		lostAt time.Time
+		cause  error
+	)
+	return func(c serial.StateChange) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch c.State {
+		case serial.StateReconnecting:
+			if lostAt.IsZero() {
+				lostAt, cause = c.At, c.Err
+			}
+			return
+		case serial.StateConnecting:
+			return
+		}
+		if lostAt.IsZero() {
+			return
+		}
+
+		message := fmt.Sprintf("port lost: %v", cause)
+		switch c.State {
+		case serial.StateConnected:
+			message += "; reconnected"
+			sl.stats.reconnect()
+		case serial.StateFailed:
+			message += fmt.Sprintf("; gave up: %v", c.Err)
+		case serial.StateStopped:
+			message += "; collection stopped while disconnected"
+		}
+		sl.recordEvent(&entity.Event{
+			ExperimentID: experimentID,
+			Kind:         entity.EventDisconnect,
+			Message:      message,
+			StartedAt:    lostAt,
+			EndedAt:      c.At,
+		})
+		lostAt = time.Time{}
+	}
+}
+
+func (sl *SerialListener) recordEvent(event *entity.Event) {
+	event.Port = sl.name
+	if err := sl.eventUC.RecordEvent(context.Background(), event); err != nil {
+		log.Printf("Failed to record %s event for experiment %d: %v", event.Kind, event.ExperimentID, err)
+	}
+}
+
+// newParser creates the parser for an experiment: the one chosen for the
+// experiment if any, the port's parser otherwise. Modbus and SCPI ports
+// always use the parser their records are built for.
+func (sl *SerialListener) newParser(ctx context.Context, experimentID int, portCfg config.PortConfig) (parser.Parser, error) {
+	experiment, err := sl.experimentUC.GetExperimentByID(ctx, experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := portCfg.Parser
+	if experiment.Parser.Type != "" && !portCfg.Modbus.Enabled() && !portCfg.SCPI.Enabled() {
+		cfg.Type = experiment.Parser.Type
+		cfg.Separator = experiment.Parser.Separator
+		cfg.Pattern = experiment.Parser.Pattern
+	}
+	return parser.New(cfg, experiment.Channels)
+}
+
+// newMeasurement turns a frame into a measurement. Value holds the frame
+// as text, or the decoded channels if the frame is binary. The raw frame is
+// always kept, and frames that fail to parse are stored with the error.
+// Nil is returned for blank lines.
+func newMeasurement(experimentID int, frame []byte, p parser.Parser) *entity.Measurement {
+	m := &entity.Measurement{
+		ExperimentID: experimentID,
+		Raw:          frame,
+	}
+
+	text := isText(frame)
+	if text {
+		m.Value = strings.TrimSpace(string(frame))
+		if m.Value == "" {
+			return nil
+		}
+	}
+
+	values, err := p.Parse(frame)
+	if err != nil {
+		log.Printf("Failed to parse frame %q: %v", frame, err)
+		m.ParseError = err.Error()
+	} else {
+		m.Values = values
+	}
+
+	if !text {
+		if len(m.Values) > 0 {
+			m.Value = formatValues(m.Values)
+		} else {
+			m.Value = hex.EncodeToString(frame)
+		}
+	}
+	return m
+}
+
+// isText reports whether the frame is printable UTF-8 text.
+func isText(frame []byte) bool {
+	if !utf8.Valid(frame) {
+		return false
+	}
+	for _, r := range string(frame) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatValues(values []entity.ChannelValue) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%s=%s", v.Channel, v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Name returns the configured name of the port.
+func (sl *SerialListener) Name() string {
+	return sl.name
+}
+
+// Config returns the current settings of the port.
+func (sl *SerialListener) Config() config.PortConfig {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return sl.cfg
+}
+
+// SetDevice points the port at another device path. It is refused while
+// the port is collecting data. The USB match, if any, is dropped: the
+// operator has picked the device explicitly.
+func (sl *SerialListener) SetDevice(device string) error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if sl.isRunning {
+		return fmt.Errorf("%w: port %s is collecting data for experiment %d",
+			ErrPortBusy, sl.name, sl.currentExpID)
+	}
+
+	sl.cfg.Device = device
+	sl.cfg.Match = config.DeviceMatch{}
+	if source, _, ok := config.NetworkAddress(device); ok {
+		sl.cfg.Source = source
+	} else if sl.cfg.Source == config.SourceTCP || sl.cfg.Source == config.SourceRFC2217 {
+		sl.cfg.Source = config.SourceSerial
+	}
+	sl.source = nil
+	return nil
+}
+
+// Wake asks a port waiting to reconnect to try again now.
+func (sl *SerialListener) Wake() {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if w, ok := sl.source.(serial.Waker); ok && sl.isRunning {
+		w.Wake()
+	}
+}
+
 func (sl *SerialListener) IsRunning() bool {
 	sl.mu.Lock()
 	defer sl.mu.Unlock()
@@ -193,10 +844,72 @@ func (sl *SerialListener) Status() map[string]any {
 	sl.mu.Lock()
 	defer sl.mu.Unlock()
 
-	return map[string]any{
+	portCfg := sl.cfg
+	src := serial.SourceStatus{Device: portCfg.Device, State: serial.StateInfo{State: serial.StateStopped}}
+	if sl.source != nil {
+		src = sl.source.Status()
+	}
+	state := src.State
+	var shareClients int
+	if sl.share != nil {
+		shareClients = sl.share.Clients()
+	}
+	pollQuery := portCfg.Poll.Query
+	switch {
+	case portCfg.Modbus.Enabled():
+		pollQuery = fmt.Sprintf("Modbus RTU, %d registers", len(portCfg.Modbus.Registers))
+	case portCfg.SCPI.Enabled():
+		pollQuery = fmt.Sprintf("SCPI, %d queries", len(portCfg.SCPI.Queries))
+	}
+	var match string
+	if !portCfg.Match.IsEmpty() {
+		match = portCfg.Match.String()
+	}
+	status := map[string]any{
+		"name":               portCfg.Name,
 		"is_running":         sl.isRunning,
 		"current_experiment": sl.currentExpID,
-		"port":               sl.portListener.Name(),
-		"baud_rate":          sl.portListener.BaudRate(),
+		"source":             portCfg.Source,
+		"port":               src.Device,
+		"match":              match,
+		"baud_rate":          portCfg.BaudRate,
+		"data_bits":          portCfg.DataBits,
+		"parity":             portCfg.Parity,
+		"stop_bits":          portCfg.StopBits,
+		"flow_control":       portCfg.FlowControl,
+		"line_settings":      portCfg.LineSettings(),
+		"framing":            portCfg.Framing.Type,
+		"encoding":           portCfg.Encoding,
+		"frame_errors":       src.FrameErrors,
+		"polling":            portCfg.Poll.Enabled() || portCfg.Modbus.Enabled() || portCfg.SCPI.Enabled(),
+		"poll_query":         pollQuery,
+		"poll_timeouts":      src.PollTimeouts,
+		"state":              state.State,
+		"state_since":        state.Since,
+		"last_error":         state.LastErr,
+		"reconnect_attempts": state.Attempts,
+		"watchdog":           portCfg.Watchdog.Timeout.String(),
+		"stalled":            !sl.stalledSince.IsZero(),
+		"time_source":        portCfg.TimeSource,
+		"instrument":         sl.instrument,
+		"checksum":           portCfg.Checksum.Type,
+		"checksum_valid":     sl.validFrames.Load(),
+		"checksum_invalid":   sl.invalidFrames.Load(),
+		"capture_dir":        portCfg.Capture.Dir,
+		"share":              portCfg.Share.Listen,
+		"share_mode":         portCfg.Share.Mode,
+		"share_clients":      shareClients,
+		"sequence":           portCfg.Sequence.Channel,
+		"sequence_missing":   sl.seqMissing.Load(),
+		"sequence_duplicate": sl.seqDuplicates.Load(),
+		"sequence_late":      sl.seqLate.Load(),
+		"stats":              sl.stats.report(src.BytesRead),
+	}
+	if sl.clock != nil {
+		status["gps_clock"] = sl.clock.status()
+	}
+	if !sl.stalledSince.IsZero() {
+		status["stalled_since"] = sl.stalledSince
 	}
+	return status
 }
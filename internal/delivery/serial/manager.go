@@ -0,0 +1,162 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
+	"github.com/physicist2018/gomodserial-v1/internal/usecase"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+var ErrUnknownPort = errors.New("unknown port")
+
+// Manager runs one SerialListener per configured port, so several
+// instruments can feed their own experiments at the same time.
+type Manager struct {
+	listeners map[string]*SerialListener
+	names     []string
+
+	deviceMu sync.Mutex // held from the check of a new device to its assignment
+}
+
+func NewManager(ports []config.PortConfig, experimentUC *usecase.ExperimentUseCase, measurementUC *usecase.MeasurementUseCase, eventUC *usecase.EventUseCase) *Manager {
+	m := &Manager{
+		listeners: make(map[string]*SerialListener, len(ports)),
+	}
+	for _, pc := range ports {
+		m.listeners[pc.Name] = NewSerialListener(pc, experimentUC, measurementUC, eventUC)
+		m.names = append(m.names, pc.Name)
+	}
+
+	// Порты с источником времени получают часы GPS-порта
+	for _, pc := range ports {
+		if pc.TimeSource == "" {
+			continue
+		}
+		source := m.listeners[pc.TimeSource]
+		if source.clock == nil {
+			source.clock = &gpsClock{}
+		}
+		m.listeners[pc.Name].timeSource = source.clock
+	}
+	return m
+}
+
+// Names returns the port names in configuration order.
+func (m *Manager) Names() []string {
+	return m.names
+}
+
+func (m *Manager) Listener(port string) (*SerialListener, error) {
+	sl, ok := m.listeners[port]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPort, port)
+	}
+	return sl, nil
+}
+
+// Start begins collecting data from the port into the experiment. A port
+// that is already collecting data is refused with ErrPortBusy.
+func (m *Manager) Start(port string, experimentID int) error {
+	sl, err := m.Listener(port)
+	if err != nil {
+		return err
+	}
+	return sl.Start(experimentID)
+}
+
+func (m *Manager) Stop(port string) error {
+	sl, err := m.Listener(port)
+	if err != nil {
+		return err
+	}
+	return sl.Stop()
+}
+
+func (m *Manager) StopAll() {
+	for _, name := range m.names {
+		m.listeners[name].Stop()
+	}
+}
+
+// ExperimentPort returns the name of the port that is collecting data for
+// the experiment, or "" if none is.
+func (m *Manager) ExperimentPort(experimentID int) string {
+	for _, name := range m.names {
+		sl := m.listeners[name]
+		if sl.IsRunning() && sl.CurrentExperimentID() == experimentID {
+			return name
+		}
+	}
+	return ""
+}
+
+// CaptureDirs returns the directories the ports save raw captures to.
+func (m *Manager) CaptureDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, name := range m.names {
+		dir := m.listeners[name].Config().Capture.Dir
+		if dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Status returns the status of every port in configuration order.
+func (m *Manager) Status() []map[string]any {
+	status := make([]map[string]any, 0, len(m.names))
+	for _, name := range m.names {
+		status = append(status, m.listeners[name].Status())
+	}
+	return status
+}
+
+// SetDevice points the port at another device. A device can serve only one
+// port, so a device configured for another port is refused.
+func (m *Manager) SetDevice(port, device string) error {
+	sl, err := m.Listener(port)
+	if err != nil {
+		return err
+	}
+
+	// Иначе два запроса могли бы одновременно занять одно устройство
+	m.deviceMu.Lock()
+	defer m.deviceMu.Unlock()
+	for _, name := range m.names {
+		if name != port && m.listeners[name].Config().Device == device {
+			return fmt.Errorf("%w: device %s is assigned to port %s", ErrPortBusy, device, name)
+		}
+	}
+	if err := sl.SetDevice(device); err != nil {
+		return err
+	}
+	log.Printf("Port %s now uses device %s", port, device)
+	return nil
+}
+
+// WatchHotplug polls the system for newly attached serial devices until ctx
+// is cancelled. Ports waiting for a device that has just appeared, either
+// at its configured path or matched by USB IDs, reconnect immediately.
+func (m *Manager) WatchHotplug(ctx context.Context, interval time.Duration) {
+	serial.WatchPorts(ctx, interval, func(added []serial.PortInfo) {
+		for _, info := range added {
+			log.Printf("Serial device attached: %s", info.Name)
+			for _, name := range m.names {
+				sl := m.listeners[name]
+				cfg := sl.Config()
+				if info.Name == cfg.Device || info.Matches(cfg.Match) {
+					log.Printf("Device for port %s is available at %s", name, info.Name)
+					sl.Wake()
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package serial_test
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/delivery/serial"
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/database"
+	infraserial "github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/simulator"
+	"github.com/physicist2018/gomodserial-v1/internal/usecase"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// TestManagerStoresSimulatedData collects an experiment from the simulated
+// device into an in-memory database, unplugs the device on the way and
+// checks what was stored.
+func TestManagerStoresSimulatedData(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the simulator needs a Linux pseudo-terminal")
+	}
+
+	simCfg := config.SimulatorConfig{
+		Link:     filepath.Join(t.TempDir(), "ttySIM"),
+		Interval: config.Duration(20 * time.Millisecond),
+		Waves:    []config.Wave{{Name: "v", Shape: config.WaveConstant, Offset: 1.5}},
+		Counter:  "n",
+		Seed:     1,
+	}
+	if err := simCfg.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	sim, err := simulator.New(simCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	go sim.Run(ctx)
+
+	repo, err := database.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	experimentUC := usecase.NewExperimentUseCase(repo)
+	measurementUC := usecase.NewMeasurementUseCase(repo)
+	eventUC := usecase.NewEventUseCase(repo)
+
+	portCfg := config.PortConfig{
+		Name:     "sim",
+		Device:   simCfg.Link,
+		BaudRate: 115200,
+		DTR:      true,
+		RTS:      true,
+		Parser:   config.ParserConfig{Type: config.ParserCSV},
+		Sequence: config.SequenceConfig{Channel: "n"},
+		Reconnect: config.ReconnectConfig{
+			InitialDelay: config.Duration(50 * time.Millisecond),
+			MaxDelay:     config.Duration(200 * time.Millisecond),
+		},
+	}
+	if err := portCfg.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	m := serial.NewManager([]config.PortConfig{portCfg}, experimentUC, measurementUC, eventUC)
+	defer m.StopAll()
+
+	experiment, err := experimentUC.CreateExperiment(ctx, entity.Experiment{
+		Name:     "simulated",
+		Port:     "sim",
+		Channels: []entity.Channel{{Name: "n"}, {Name: "v"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start("sim", experiment.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// waitFor polls until at least n measurements are stored.
+	waitFor := func(n int) {
+		t.Helper()
+		for {
+			stored, err := measurementUC.GetMeasurementsByExperimentID(ctx, experiment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) >= n {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("%d of %d measurements stored", len(stored), n)
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+	waitFor(5)
+
+	if err := sim.Disconnect(ctx, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	before, err := measurementUC.GetMeasurementsByExperimentID(ctx, experiment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(len(before) + 5)
+	sl, err := m.Listener("sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := sl.Status()["state"]; state != infraserial.StateConnected {
+		t.Fatalf("port is %v after the device came back", state)
+	}
+	if port := m.ExperimentPort(experiment.ID); port != "sim" {
+		t.Fatalf("experiment is collected by port %q", port)
+	}
+	if err := m.Stop("sim"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := measurementUC.GetMeasurementsByExperimentID(ctx, experiment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Первая запись может быть обрезанной, если порт открылся посреди неё
+	var last int64 = -1
+	for _, ms := range stored[1:] {
+		if ms.ParseError != "" {
+			t.Fatalf("measurement %q: %s", ms.Value, ms.ParseError)
+		}
+		if v, ok := ms.ValueOf("v"); !ok || v.Value != 1.5 {
+			t.Fatalf("measurement %q has v = %v", ms.Value, v)
+		}
+		if ms.Sequence == nil || *ms.Sequence <= last {
+			t.Fatalf("measurement %q does not advance the counter from %d", ms.Value, last)
+		}
+		last = *ms.Sequence
+	}
+
+	events, err := eventUC.GetEventsByExperimentID(ctx, experiment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var disconnects, gaps int
+	for _, e := range events {
+		switch e.Kind {
+		case entity.EventDisconnect:
+			disconnects++
+			if !strings.Contains(e.Message, "reconnected") || !e.EndedAt.After(e.StartedAt) {
+				t.Fatalf("disconnect event %q from %s to %s", e.Message, e.StartedAt, e.EndedAt)
+			}
+		case entity.EventSequenceGap:
+			// Записи, сделанные при отключённом устройстве, потеряны
+			gaps++
+		default:
+			t.Fatalf("unexpected %s event: %s", e.Kind, e.Message)
+		}
+		if e.Port != "sim" {
+			t.Fatalf("%s event is recorded for port %q", e.Kind, e.Port)
+		}
+	}
+	if disconnects != 1 || gaps != 1 {
+		t.Fatalf("got %d disconnect and %d sequence gap events, expected one of each", disconnects, gaps)
+	}
+}
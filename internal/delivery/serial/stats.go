@@ -0,0 +1,162 @@
+package serial
+
+import (
+	"sync"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+)
+
+// statsInterval is how often the counters are sampled for the rates.
+const statsInterval = time.Second
+
+// rateWindows are the sliding windows the rates are averaged over.
+var rateWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"10s", 10 * time.Second},
+	{"1m", time.Minute},
+}
+
+// sessionStats counts what the port read during the current session.
+type sessionStats struct {
+	mu          sync.Mutex
+	startedAt   time.Time
+	frames      uint64
+	parsed      uint64
+	parseErrors uint64
+	dbErrors    uint64
+	reconnects  uint64
+	lastFrame   time.Time
+	samples     []statsSample // oldest first, covering the longest window
+}
+
+type statsSample struct {
+	at     time.Time
+	bytes  uint64
+	frames uint64
+}
+
+func (s *sessionStats) reset(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startedAt = now
+	s.frames, s.parsed, s.parseErrors, s.dbErrors, s.reconnects = 0, 0, 0, 0, 0
+	s.lastFrame = time.Time{}
+	s.samples = []statsSample{{at: now}}
+}
+
+func (s *sessionStats) frame(at time.Time) {
+	s.mu.Lock()
+	s.frames++
+	s.lastFrame = at
+	s.mu.Unlock()
+}
+
+// measurement counts a frame stored as a measurement, with or without a
+// parse error.
+func (s *sessionStats) measurement(m *entity.Measurement) {
+	s.mu.Lock()
+	if m.ParseError == "" {
+		s.parsed++
+	} else {
+		s.parseErrors++
+	}
+	s.mu.Unlock()
+}
+
+func (s *sessionStats) dbError() {
+	s.mu.Lock()
+	s.dbErrors++
+	s.mu.Unlock()
+}
+
+func (s *sessionStats) reconnect() {
+	s.mu.Lock()
+	s.reconnects++
+	s.mu.Unlock()
+}
+
+// sample records the counters for the rates, bytes being the number of
+// bytes the source has read so far.
+func (s *sessionStats) sample(now time.Time, bytes uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samples = append(s.samples, statsSample{at: now, bytes: bytes, frames: s.frames})
+	// Одна выборка старше самого длинного окна нужна для его начала
+	longest := rateWindows[len(rateWindows)-1].d
+	for len(s.samples) > 2 && now.Sub(s.samples[1].at) >= longest {
+		s.samples = s.samples[1:]
+	}
+}
+
+// rates returns frames and bytes per second over the last window, or over
+// the session if it is shorter.
+func (s *sessionStats) rates(window time.Duration) (frames, bytes float64) {
+	if len(s.samples) < 2 {
+		return 0, 0
+	}
+	last := s.samples[len(s.samples)-1]
+	first := s.samples[0]
+	for _, sample := range s.samples {
+		if last.at.Sub(sample.at) <= window {
+			first = sample
+			break
+		}
+	}
+	d := last.at.Sub(first.at).Seconds()
+	if d <= 0 {
+		return 0, 0
+	}
+	return float64(last.frames-first.frames) / d, float64(last.bytes-first.bytes) / d
+}
+
+// report returns the counters and rates for the port status. bytes is the
+// current number of bytes read, fresher than the last sample.
+func (s *sessionStats) report(bytes uint64) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := map[string]any{
+		"started_at":   s.startedAt,
+		"bytes_read":   bytes,
+		"frames":       s.frames,
+		"parsed":       s.parsed,
+		"parse_errors": s.parseErrors,
+		"db_errors":    s.dbErrors,
+		"reconnects":   s.reconnects,
+	}
+	if !s.lastFrame.IsZero() {
+		report["last_frame_at"] = s.lastFrame
+	}
+	for _, w := range rateWindows {
+		frames, bytes := s.rates(w.d)
+		report["frames_per_sec_"+w.name] = frames
+		report["bytes_per_sec_"+w.name] = bytes
+	}
+	return report
+}
+
+// summary returns the stats of a session that ended at now.
+func (s *sessionStats) summary(now time.Time, bytes uint64) entity.SessionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := entity.SessionStats{
+		StartedAt:   s.startedAt,
+		EndedAt:     now,
+		BytesRead:   int64(bytes),
+		Frames:      int64(s.frames),
+		Parsed:      int64(s.parsed),
+		ParseErrors: int64(s.parseErrors),
+		DBErrors:    int64(s.dbErrors),
+		Reconnects:  int64(s.reconnects),
+	}
+	if !s.lastFrame.IsZero() {
+		lastFrame := s.lastFrame
+		stats.LastFrameAt = &lastFrame
+	}
+	return stats
+}
@@ -0,0 +1,140 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// watchdog notices a device that stopped sending while its port stays
+// open; reading just keeps waiting then, and nothing else would notice.
+type watchdog struct {
+	cfg     config.WatchdogConfig
+	timer   *time.Timer
+	last    time.Time // of the last frame, or of the start of the session
+	stalled bool
+}
+
+func newWatchdog(cfg config.WatchdogConfig, now time.Time) *watchdog {
+	return &watchdog{cfg: cfg, timer: time.NewTimer(time.Duration(cfg.Timeout)), last: now}
+}
+
+// C returns the channel the watchdog fires on; nil if there is no
+// watchdog, so that it never fires.
+func (w *watchdog) C() <-chan time.Time {
+	if w == nil {
+		return nil
+	}
+	return w.timer.C
+}
+
+func (w *watchdog) stop() {
+	if w != nil {
+		w.timer.Stop()
+	}
+}
+
+// rearm makes the watchdog fire after d. It must not be called while the
+// timer may have fired unread.
+func (w *watchdog) rearm(d time.Duration) {
+	if !w.timer.Stop() {
+		select {
+		case <-w.timer.C:
+		default:
+		}
+	}
+	w.timer.Reset(d)
+}
+
+// watchdogFrame restarts the watchdog for a frame received at the given
+// time and records the end of a stall.
+func (sl *SerialListener) watchdogFrame(experimentID int, w *watchdog, received time.Time) {
+	if w == nil {
+		return
+	}
+	if w.stalled {
+		w.stalled = false
+		sl.setStalled(time.Time{})
+		silence := received.Sub(w.last).Round(time.Second)
+		log.Printf("Port %s: device is sending again after %s of silence", sl.name, silence)
+		sl.recordEvent(&entity.Event{
+			ExperimentID: experimentID,
+			Kind:         entity.EventStall,
+			Message:      fmt.Sprintf("device is sending again after %s of silence", silence),
+			StartedAt:    w.last,
+			EndedAt:      received,
+		})
+	}
+	w.last = received
+	w.rearm(time.Duration(w.cfg.Timeout))
+}
+
+// watchdogFired handles the timer of the watchdog. The time the port was
+// not connected does not count as silence: that is a disconnect.
+func (sl *SerialListener) watchdogFired(ctx context.Context, experimentID int, portCfg config.PortConfig, port serial.DataSource, w *watchdog, now time.Time) {
+	timeout := time.Duration(w.cfg.Timeout)
+	state := port.Status().State
+	if state.State != serial.StateConnected {
+		w.timer.Reset(timeout)
+		return
+	}
+	since := w.last
+	if state.Since.After(since) {
+		since = state.Since
+	}
+	if left := timeout - now.Sub(since); left > 0 {
+		w.timer.Reset(left)
+		return
+	}
+	if w.stalled {
+		// Тревога уже поднята, ждём данных
+		return
+	}
+
+	w.stalled, w.last = true, since
+	sl.setStalled(since)
+	actions := []string{fmt.Sprintf("no data for %s", now.Sub(since).Round(time.Second))}
+	if w.cfg.PulseDTR {
+		if pulser, ok := port.(serial.DTRPulser); !ok {
+			actions = append(actions, fmt.Sprintf("source %s cannot pulse DTR", portCfg.Source))
+		} else if err := pulser.PulseDTR(); err != nil {
+			actions = append(actions, fmt.Sprintf("failed to pulse DTR: %v", err))
+		} else {
+			actions = append(actions, "DTR pulsed")
+		}
+	}
+	if w.cfg.WakeCommand != "" {
+		writeCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := port.Write(writeCtx, []byte(w.cfg.WakeCommand+portCfg.CommandEnding))
+		cancel()
+		if err != nil {
+			actions = append(actions, fmt.Sprintf("failed to send %q: %v", w.cfg.WakeCommand, err))
+		} else {
+			actions = append(actions, fmt.Sprintf("sent %q", w.cfg.WakeCommand))
+		}
+	}
+
+	message := strings.Join(actions, "; ")
+	log.Printf("Port %s: device stalled: %s", sl.name, message)
+	sl.recordEvent(&entity.Event{
+		ExperimentID: experimentID,
+		Kind:         entity.EventStall,
+		Message:      message,
+		StartedAt:    since,
+		EndedAt:      now,
+	})
+}
+
+// setStalled marks the port as silent since the given time; zero clears
+// the alert.
+func (sl *SerialListener) setStalled(since time.Time) {
+	sl.mu.Lock()
+	sl.stalledSince = since
+	sl.mu.Unlock()
+}
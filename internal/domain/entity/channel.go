@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type ChannelType string
+
+const (
+	ChannelNumeric ChannelType = "numeric"
+	ChannelText    ChannelType = "text"
+	ChannelBool    ChannelType = "bool"
+)
+
+func (t ChannelType) Valid() bool {
+	switch t {
+	case ChannelNumeric, ChannelText, ChannelBool:
+		return true
+	}
+	return false
+}
+
+// Channel is one named quantity declared by an experiment, e.g. a
+// temperature in °C. Position is the column of the channel in a line.
+type Channel struct {
+	ID           int         `json:"id"`
+	ExperimentID int         `json:"experiment_id"`
+	Position     int         `json:"position"`
+	Name         string      `json:"name"`
+	Unit         string      `json:"unit"`
+	Type         ChannelType `json:"type"`
+}
+
+// Title returns the channel name with its unit, suitable for a column header.
+func (c Channel) Title() string {
+	if c.Unit == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s, %s", c.Name, c.Unit)
+}
+
+// ChannelValue is one typed value of a measurement. Numeric and boolean
+// values are kept in Value (booleans as 0 or 1), text values in Text.
+type ChannelValue struct {
+	Channel string
+	Type    ChannelType
+	Value   float64
+	Text    string
+}
+
+func NumericValue(channel string, value float64) ChannelValue {
+	return ChannelValue{Channel: channel, Type: ChannelNumeric, Value: value}
+}
+
+func TextValue(channel, text string) ChannelValue {
+	return ChannelValue{Channel: channel, Type: ChannelText, Text: text}
+}
+
+func BoolValue(channel string, value bool) ChannelValue {
+	v := ChannelValue{Channel: channel, Type: ChannelBool}
+	if value {
+		v.Value = 1
+	}
+	return v
+}
+
+// Any returns the value as float64, string or bool depending on its type.
+func (v ChannelValue) Any() any {
+	switch v.Type {
+	case ChannelText:
+		return v.Text
+	case ChannelBool:
+		return v.Value != 0
+	default:
+		return v.Value
+	}
+}
+
+func (v ChannelValue) String() string {
+	switch v.Type {
+	case ChannelText:
+		return v.Text
+	case ChannelBool:
+		return strconv.FormatBool(v.Value != 0)
+	default:
+		return strconv.FormatFloat(v.Value, 'g', -1, 64)
+	}
+}
+
+func (v ChannelValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Channel string      `json:"channel"`
+		Type    ChannelType `json:"type"`
+		Value   any         `json:"value"`
+	}{v.Channel, v.Type, v.Any()})
+}
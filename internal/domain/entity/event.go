@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// EventDisconnect covers the time the port of a running experiment was
+	// not connected, so no data could be recorded.
+	EventDisconnect = "disconnect"
+	// EventCommand is a command sent to the device, EventResponse the frame
+	// that answered it.
+	EventCommand  = "command"
+	EventResponse = "response"
+	// EventTimeout covers polls of the device that got no response.
+	EventTimeout = "timeout"
+	// EventIdentity is the identity an instrument reported on connect,
+	// EventInstrumentError an error it reported in its error queue.
+	EventIdentity        = "identity"
+	EventInstrumentError = "instrument_error"
+	// EventStall covers a silence of a connected device longer than the
+	// watchdog timeout of its port.
+	EventStall = "stall"
+)
+
+// Event is something that happened on the port during an experiment.
+// Events that last have an end time; instant events have EndedAt equal to
+// StartedAt.
+type Event struct {
+	ID           int       `json:"id"`
+	ExperimentID int       `json:"experiment_id"`
+	Port         string    `json:"port"`
+	Kind         string    `json:"kind"`
+	Message      string    `json:"message"`
+	StartedAt    time.Time `json:"started_at"`
+	EndedAt      time.Time `json:"ended_at"`
+}
+
+func (e Event) Duration() time.Duration {
+	return e.EndedAt.Sub(e.StartedAt)
+}
+
+type EventRepository interface {
+	CreateEvent(ctx context.Context, event *Event) error
+	GetEventsByExperimentID(ctx context.Context, experimentID int) ([]Event, error)
+}
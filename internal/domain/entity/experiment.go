@@ -10,10 +10,27 @@ type Experiment struct {
 	Name        string
 	Description string
 	CreatedAt   time.Time
+	Port        string // name of the port that feeds the experiment
+	Channels    []Channel
+	Parser      ParserSpec
+	Instrument  string // identity reported by the instrument, e.g. the answer to *IDN?
+}
+
+// ParserSpec selects how the lines of an experiment are parsed. An empty
+// Type means the parser configured for the port is used.
+type ParserSpec struct {
+	Type      string
+	Separator string
+	Pattern   string
 }
 
 type ExperimentRepository interface {
 	CreateExperiment(ctx context.Context, experiment *Experiment) (int, error)
 	GetAllExperiments(ctx context.Context) ([]Experiment, error)
 	GetExperimentByID(ctx context.Context, id int) (*Experiment, error)
+	GetChannelsByExperimentID(ctx context.Context, experimentID int) ([]Channel, error)
+	SetExperimentInstrument(ctx context.Context, id int, instrument string) error
+	DeleteExperiment(ctx context.Context, id int) error
+	SaveSessionStats(ctx context.Context, stats *SessionStats) error
+	GetSessionStats(ctx context.Context, experimentID int) ([]SessionStats, error)
 }
@@ -2,17 +2,54 @@ package entity
 
 import (
 	"context"
+	"strconv"
 	"time"
 )
 
 type Measurement struct {
+	ID           int            `json:"id"`
+	ExperimentID int            `json:"experiment_id"`
+	Value        string         `json:"value"`
+	Raw          []byte         `json:"raw,omitempty"`
+	Values       []ChannelValue `json:"values,omitempty"`
+	ParseError   string         `json:"parse_error,omitempty"`
+	Timestamp    time.Time      `json:"timestamp"`             // host time the first byte of the frame was read
+	DeviceTime   *time.Time     `json:"device_time,omitempty"` // time the device gave the measurement, if configured
+	GPSTime      *time.Time     `json:"gps_time,omitempty"`    // Timestamp corrected by the GPS clock of the port's time source
+	Sequence     *int64         `json:"sequence,omitempty"`    // rolling counter of the device, if configured
+}
+
+// ValueOf returns the value of the named channel.
+func (m Measurement) ValueOf(channel string) (ChannelValue, bool) {
+	for _, v := range m.Values {
+		if v.Channel == channel {
+			return v, true
+		}
+	}
+	return ChannelValue{}, false
+}
+
+// QuarantinedFrame is a frame that failed its integrity check. It is kept
+// apart from the measurements so that corrupted data is never taken for
+// valid data.
+type QuarantinedFrame struct {
 	ID           int       `json:"id"`
 	ExperimentID int       `json:"experiment_id"`
-	Value        string    `json:"value"`
-	Timestamp    time.Time `json:"timestamp"`
+	Port         string    `json:"port"`
+	Raw          []byte    `json:"raw"`
+	Reason       string    `json:"reason"`
+	ReceivedAt   time.Time `json:"received_at"`
+}
+
+// Quoted returns the frame as a quoted string, with corrupted bytes
+// escaped.
+func (f QuarantinedFrame) Quoted() string {
+	return strconv.Quote(string(f.Raw))
 }
 
 type MeasurementRepository interface {
 	CreateMeasurement(ctx context.Context, measurement *Measurement) error
 	GetMeasurementsByExperimentID(ctx context.Context, experimentID int) ([]Measurement, error)
+	QuarantineFrame(ctx context.Context, frame *QuarantinedFrame) error
+	GetQuarantinedFrames(ctx context.Context, experimentID int) ([]QuarantinedFrame, error)
 }
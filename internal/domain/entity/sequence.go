@@ -0,0 +1,164 @@
+package entity
+
+import "time"
+
+// Kinds of sequence issues; they are recorded as events of the same kind.
+const (
+	EventSequenceGap  = "sequence_gap"  // records are missing
+	EventDuplicate    = "duplicate"     // a record arrived twice
+	EventOutOfOrder   = "out_of_order"  // a record arrived after a later one
+	EventCounterReset = "counter_reset" // the counter started over, e.g. after a reboot
+)
+
+// A counter that goes back by more than resetJump, or that keeps going back
+// or repeating for resyncAfter records in a row, is taken to have started
+// over; otherwise a rebooted device would make every later record late.
+// Late records that fill a gap do not count towards a resync. Only the
+// last maxOpenGaps gaps are kept for them.
+const (
+	resetJump   = 1000
+	resyncAfter = 3
+	maxOpenGaps = 64
+)
+
+// SequenceIssue is a break in the rolling counter of a device. For a gap
+// Missing records are lost between From, the time of the last record in
+// sequence, and To, the time of the record that follows the gap. A late
+// record is Filled if it is one of the records a gap counted as missing.
+type SequenceIssue struct {
+	Kind     string    `json:"kind"`
+	Expected int64     `json:"expected"`
+	Got      int64     `json:"got"`
+	Missing  int64     `json:"missing,omitempty"`
+	Filled   bool      `json:"filled,omitempty"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+}
+
+func (i SequenceIssue) Duration() time.Duration {
+	return i.To.Sub(i.From)
+}
+
+// SequenceTracker follows the rolling counter of a device. A counter with
+// a Modulus wraps from Modulus-1 to 0; a counter without one only grows.
+// With a modulus, a jump of less than half of it is taken for a gap and a
+// larger one for a record that arrived late. A counter that started over
+// is reported once, and tracking continues from the new value.
+type SequenceTracker struct {
+	Modulus int64
+
+	started bool
+	last    int64
+	lastAt  time.Time
+	behind  int        // records in a row that did not advance the counter
+	gaps    []seqRange // numbers of the gaps that have not arrived yet
+}
+
+// Next checks the counter of the next record and returns the issue it
+// reveals, if any. Duplicates and late records do not move the tracker
+// unless they show that the counter was reset.
+func (t *SequenceTracker) Next(seq int64, at time.Time) *SequenceIssue {
+	if t.Modulus > 0 {
+		seq = mod(seq, t.Modulus)
+	}
+	if !t.started {
+		t.started, t.last, t.lastAt = true, seq, at
+		return nil
+	}
+
+	expected := t.last + 1
+	diff := seq - t.last
+	if t.Modulus > 0 {
+		expected = mod(expected, t.Modulus)
+		diff = mod(diff, t.Modulus)
+		if diff > t.Modulus/2 {
+			diff -= t.Modulus
+		}
+	}
+
+	issue := &SequenceIssue{Expected: expected, Got: seq, From: t.lastAt, To: at}
+	if diff < 0 && t.fillGap(seq) {
+		issue.Kind, issue.Filled = EventOutOfOrder, true
+		return issue
+	}
+	if diff <= 0 {
+		t.behind++
+	} else {
+		t.behind = 0
+	}
+	switch {
+	case diff == 1:
+		issue = nil
+	case -diff > resetJump || t.behind >= resyncAfter:
+		// Номера после сброса с прежними пропусками не связаны
+		issue.Kind = EventCounterReset
+		t.behind = 0
+		t.gaps = nil
+	case diff == 0:
+		issue.Kind = EventDuplicate
+		return issue
+	case diff < 0:
+		issue.Kind = EventOutOfOrder
+		return issue
+	default:
+		issue.Kind = EventSequenceGap
+		issue.Missing = diff - 1
+		if len(t.gaps) == maxOpenGaps {
+			t.gaps = t.gaps[1:]
+		}
+		t.gaps = append(t.gaps, seqRange{start: expected, count: issue.Missing})
+	}
+	t.last, t.lastAt = seq, at
+	return issue
+}
+
+// seqRange is count sequence numbers from start, wrapping at the modulus
+// if there is one.
+type seqRange struct {
+	start, count int64
+}
+
+// offset returns the position of seq in the range, or -1.
+func (r seqRange) offset(seq, modulus int64) int64 {
+	k := seq - r.start
+	if modulus > 0 {
+		k = mod(k, modulus)
+	}
+	if k < 0 || k >= r.count {
+		return -1
+	}
+	return k
+}
+
+// fillGap takes seq out of the open gap it falls in, splitting the gap if
+// seq is inside it. It reports false if seq is in no gap.
+func (t *SequenceTracker) fillGap(seq int64) bool {
+	for i, r := range t.gaps {
+		k := r.offset(seq, t.Modulus)
+		if k < 0 {
+			continue
+		}
+		after := seqRange{start: r.start + k + 1, count: r.count - k - 1}
+		if t.Modulus > 0 {
+			after.start = mod(after.start, t.Modulus)
+		}
+		if k == 0 {
+			t.gaps = append(t.gaps[:i], t.gaps[i+1:]...)
+		} else {
+			t.gaps[i].count = k
+		}
+		if after.count > 0 {
+			t.gaps = append(t.gaps, after)
+		}
+		return true
+	}
+	return false
+}
+
+func mod(a, m int64) int64 {
+	a %= m
+	if a < 0 {
+		a += m
+	}
+	return a
+}
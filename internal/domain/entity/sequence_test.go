@@ -0,0 +1,135 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		modulus int64
+		seqs    []int64
+		want    []string // kind of the issue of every record after the first, "" for none
+		missing int64    // records counted as missing and not filled
+	}{
+		{
+			name:    "in order",
+			seqs:    []int64{1, 2, 3, 4},
+			want:    []string{"", "", ""},
+			missing: 0,
+		},
+		{
+			name:    "gap",
+			seqs:    []int64{1, 2, 5, 6},
+			want:    []string{"", EventSequenceGap, ""},
+			missing: 2,
+		},
+		{
+			name:    "reordered burst fills the gap",
+			seqs:    []int64{1, 2, 6, 3, 4, 5, 7, 8},
+			want:    []string{"", EventSequenceGap, EventOutOfOrder, EventOutOfOrder, EventOutOfOrder, "", ""},
+			missing: 0,
+		},
+		{
+			name:    "part of the gap arrives late",
+			seqs:    []int64{1, 6, 4, 7},
+			want:    []string{EventSequenceGap, EventOutOfOrder, ""},
+			missing: 3,
+		},
+		{
+			name:    "duplicates",
+			seqs:    []int64{1, 2, 2, 3, 3},
+			want:    []string{"", EventDuplicate, "", EventDuplicate},
+			missing: 0,
+		},
+		{
+			name:    "repeated late record does not fill twice",
+			seqs:    []int64{1, 4, 2, 2, 5},
+			want:    []string{EventSequenceGap, EventOutOfOrder, EventOutOfOrder, ""},
+			missing: 1,
+		},
+		{
+			name:    "large jump back is a reset",
+			seqs:    []int64{5000, 5001, 3, 4},
+			want:    []string{"", EventCounterReset, ""},
+			missing: 0,
+		},
+		{
+			name:    "small counter reset after three records",
+			seqs:    []int64{50, 51, 52, 0, 1, 2, 3},
+			want:    []string{"", "", EventOutOfOrder, EventOutOfOrder, EventCounterReset, ""},
+			missing: 0,
+		},
+		{
+			name: "reset forgets the open gaps",
+			seqs: []int64{10, 13, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 11},
+			want: []string{EventSequenceGap, EventOutOfOrder, EventOutOfOrder, EventCounterReset,
+				"", "", "", "", "", "", "", "", "", "", "", "", EventOutOfOrder},
+			missing: 2,
+		},
+		{
+			name:    "wrap",
+			modulus: 256,
+			seqs:    []int64{254, 255, 0, 1},
+			want:    []string{"", "", ""},
+			missing: 0,
+		},
+		{
+			name:    "gap across the wrap filled late",
+			modulus: 256,
+			seqs:    []int64{254, 2, 255, 0, 1, 3},
+			want:    []string{EventSequenceGap, EventOutOfOrder, EventOutOfOrder, EventOutOfOrder, ""},
+			missing: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &SequenceTracker{Modulus: tt.modulus}
+			at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			var got []string
+			var missing int64
+			for i, seq := range tt.seqs {
+				issue := tracker.Next(seq, at.Add(time.Duration(i)*time.Second))
+				if i == 0 {
+					if issue != nil {
+						t.Fatalf("first record reported %s", issue.Kind)
+					}
+					continue
+				}
+				if issue == nil {
+					got = append(got, "")
+					continue
+				}
+				got = append(got, issue.Kind)
+				missing += issue.Missing
+				if issue.Filled {
+					missing--
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues %q, want %q", got, tt.want)
+			}
+			if missing != tt.missing {
+				t.Errorf("missing %d, want %d", missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestSequenceTrackerGap(t *testing.T) {
+	tracker := &SequenceTracker{Modulus: 256}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Second)
+	tracker.Next(254, from)
+	issue := tracker.Next(2, to)
+	want := &SequenceIssue{Kind: EventSequenceGap, Expected: 255, Got: 2, Missing: 3, From: from, To: to}
+	if !reflect.DeepEqual(issue, want) {
+		t.Fatalf("got %+v, want %+v", issue, want)
+	}
+	if issue.Duration() != 3*time.Second {
+		t.Fatalf("duration %s", issue.Duration())
+	}
+}
@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// SessionStats sums up what a port read while it collected data for an
+// experiment. It is saved when collection stops, so an experiment has one
+// for every time it was started.
+type SessionStats struct {
+	ID           int        `json:"id"`
+	ExperimentID int        `json:"experiment_id"`
+	Port         string     `json:"port"`
+	StartedAt    time.Time  `json:"started_at"`
+	EndedAt      time.Time  `json:"ended_at"`
+	BytesRead    int64      `json:"bytes_read"`
+	Frames       int64      `json:"frames"`        // frames received
+	Parsed       int64      `json:"parsed"`        // frames stored without a parse error
+	ParseErrors  int64      `json:"parse_errors"`  // frames stored with one
+	FrameErrors  int64      `json:"frame_errors"`  // malformed frames dropped by the framer
+	Quarantined  int64      `json:"quarantined"`   // frames that failed the checksum
+	DBErrors     int64      `json:"db_errors"`     // measurements that could not be saved
+	Reconnects   int64      `json:"reconnects"`    // times the port was lost and opened again
+	LastFrameAt  *time.Time `json:"last_frame_at"` // nil if no frame was received
+}
+
+func (s SessionStats) Duration() time.Duration {
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// FramesPerSecond returns the average frame rate of the session.
+func (s SessionStats) FramesPerSecond() float64 {
+	return perSecond(s.Frames, s.Duration())
+}
+
+// BytesPerSecond returns the average byte rate of the session.
+func (s SessionStats) BytesPerSecond() float64 {
+	return perSecond(s.BytesRead, s.Duration())
+}
+
+func perSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
@@ -0,0 +1,223 @@
+// Package capture saves the raw bytes read from a port with the time they
+// were read.
+//
+// A capture file starts with the 8-byte magic "SERCAP1\n" and holds one
+// record per read: the time as int64 Unix nanoseconds, the length as
+// uint32 and the bytes themselves, all big-endian.
+package capture
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+const (
+	Magic = "SERCAP1\n"
+	Ext   = ".cap"
+)
+
+// Writer writes the capture files of one experiment on one port and
+// rotates them as configured.
+type Writer struct {
+	cfg    config.CaptureConfig
+	prefix string
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	compressing sync.WaitGroup
+}
+
+// NewWriter creates the directory if needed and opens the first file.
+func NewWriter(cfg config.CaptureConfig, experimentID int, port string) (*Writer, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	w := &Writer{cfg: cfg, prefix: filePrefix(experimentID) + sanitize(port) + "-"}
+	if err := w.open(time.Now()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// filePrefix starts the names of the capture files of an experiment.
+func filePrefix(experimentID int) string {
+	return fmt.Sprintf("exp%d-", experimentID)
+}
+
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func (w *Writer) open(now time.Time) error {
+	name := filepath.Join(w.cfg.Dir, w.prefix+now.Format("20060102-150405.000")+Ext)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create capture file: %w", err)
+	}
+	if _, err := f.WriteString(Magic); err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size, w.opened = f, int64(len(Magic)), now
+	return nil
+}
+
+// Write records p as read now.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.WriteAt(time.Now(), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteAt records p as read at t.
+func (w *Writer) WriteAt(t time.Time, p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	if w.size >= w.cfg.MaxSize || (w.cfg.MaxAge > 0 && t.Sub(w.opened) >= time.Duration(w.cfg.MaxAge)) {
+		if err := w.rotate(t); err != nil {
+			return err
+		}
+	}
+
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[:8], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(p)))
+	n, err := w.file.Write(append(header[:], p...))
+	w.size += int64(n)
+	return err
+}
+
+func (w *Writer) rotate(now time.Time) error {
+	name := w.file.Name()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.cfg.Compresses() {
+		w.compressing.Add(1)
+		go func() {
+			defer w.compressing.Done()
+			if err := compress(name); err != nil {
+				log.Printf("Failed to compress capture file %s: %v", name, err)
+			}
+		}()
+	}
+	return w.open(now)
+}
+
+// Close closes the current file and waits for rotated files to be
+// compressed. The current file is left uncompressed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	var err error
+	if !w.closed {
+		w.closed = true
+		err = w.file.Close()
+	}
+	w.mu.Unlock()
+
+	w.compressing.Wait()
+	return err
+}
+
+// compress replaces the file with its gzipped copy.
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(name + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(name)
+}
+
+// File is a capture file on disk.
+type File struct {
+	Name     string
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// List returns the capture files of an experiment in dir, oldest first.
+func List(dir string, experimentID int) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !IsCaptureFile(name, experimentID) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, File{
+			Name:     name,
+			Path:     filepath.Join(dir, name),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	// Имена содержат время открытия файла
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// IsCaptureFile reports whether name is a capture file of the experiment.
+func IsCaptureFile(name string, experimentID int) bool {
+	return name == filepath.Base(name) &&
+		strings.HasPrefix(name, filePrefix(experimentID)) &&
+		(strings.HasSuffix(name, Ext) || strings.HasSuffix(name, Ext+".gz"))
+}
@@ -0,0 +1,118 @@
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxRecord bounds the length of a record so that a damaged file does not
+// make the reader allocate gigabytes.
+const maxRecord = 16 << 20
+
+// Reader reads the records of capture files one after another. Gzipped
+// files are decompressed on the fly.
+type Reader struct {
+	paths []string
+
+	file *os.File
+	r    *bufio.Reader
+	name string
+}
+
+// Open opens the capture files matching pattern, a file name or a glob,
+// in name order, which for capture files of one port is the order they
+// were written.
+func Open(pattern string) (*Reader, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no capture files match %s", pattern)
+	}
+	sort.Strings(paths)
+	r := &Reader{paths: paths}
+	if err := r.next(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// next opens the next file; it returns io.EOF when there is none.
+func (r *Reader) next() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file, r.r = nil, nil
+	}
+	if len(r.paths) == 0 {
+		return io.EOF
+	}
+	r.name, r.paths = r.paths[0], r.paths[1:]
+
+	f, err := os.Open(r.name)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReader(f)
+	if head, _ := br.Peek(2); len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %w", r.name, err)
+		}
+		br = bufio.NewReader(zr)
+	}
+
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != Magic {
+		f.Close()
+		return fmt.Errorf("%s is not a capture file", r.name)
+	}
+	r.file, r.r = f, br
+	return nil
+}
+
+// Next returns the next record and the time it was read. It returns io.EOF
+// after the last record of the last file. A file cut short within a record,
+// as the current file is after a crash, ends at its last whole record.
+func (r *Reader) Next() (time.Time, []byte, error) {
+	for r.r != nil {
+		var header [12]byte
+		_, err := io.ReadFull(r.r, header[:])
+		if err == nil {
+			n := binary.BigEndian.Uint32(header[8:])
+			if n > maxRecord {
+				return time.Time{}, nil, fmt.Errorf("%s: damaged record of %d bytes", r.name, n)
+			}
+			data := make([]byte, n)
+			if _, err = io.ReadFull(r.r, data); err == nil {
+				return time.Unix(0, int64(binary.BigEndian.Uint64(header[:8]))), data, nil
+			}
+		}
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			return time.Time{}, nil, fmt.Errorf("%s: %w", r.name, err)
+		}
+		if err := r.next(); err != nil {
+			return time.Time{}, nil, err
+		}
+	}
+	return time.Time{}, nil, io.EOF
+}
+
+// Close closes the current file.
+func (r *Reader) Close() error {
+	r.paths = nil
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file, r.r = nil, nil
+	return err
+}
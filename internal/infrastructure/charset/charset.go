@@ -0,0 +1,106 @@
+// Package charset decodes the text of legacy devices to UTF-8.
+package charset
+
+import (
+	"unicode/utf8"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// Upper halves of the single-byte encodings, from 0x80; the lower half is
+// ASCII. Zero marks a byte the encoding does not define.
+var tables = map[string]*[128]rune{
+	config.EncodingCP1251: &cp1251,
+	config.EncodingCP866:  &cp866,
+	config.EncodingLatin1: &latin1,
+}
+
+var cp1251 = [128]rune{
+	0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021, 0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+	0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+	0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7, 0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+	0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7, 0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+}
+
+var cp866 = [128]rune{
+	48: 0x2591, 0x2592, 0x2593, 0x2502, 0x2524, 0x2561, 0x2562, 0x2556, 0x2555, 0x2563, 0x2551, 0x2557, 0x255D, 0x255C, 0x255B, 0x2510,
+	64: 0x2514, 0x2534, 0x252C, 0x251C, 0x2500, 0x253C, 0x255E, 0x255F, 0x255A, 0x2554, 0x2569, 0x2566, 0x2560, 0x2550, 0x256C, 0x2567,
+	80: 0x2568, 0x2564, 0x2565, 0x2559, 0x2558, 0x2552, 0x2553, 0x256B, 0x256A, 0x2518, 0x250C, 0x2588, 0x2584, 0x258C, 0x2590, 0x2580,
+}
+
+var latin1 [128]rune
+
+func init() {
+	// Кириллица А-я в CP1251 идёт подряд с 0xC0
+	for i := 0; i < 64; i++ {
+		cp1251[64+i] = 0x0410 + rune(i)
+	}
+	// В CP866 А-п с 0x80, р-я с 0xE0
+	for i := 0; i < 48; i++ {
+		cp866[i] = 0x0410 + rune(i)
+	}
+	for i := 0; i < 16; i++ {
+		cp866[96+i] = 0x0440 + rune(i)
+	}
+	copy(cp866[112:], []rune{0x0401, 0x0451, 0x0404, 0x0454, 0x0407, 0x0457, 0x040E, 0x045E, 0x00B0, 0x2219, 0x00B7, 0x221A, 0x2116, 0x00A4, 0x25A0, 0x00A0})
+	// Управляющие символы C1 (0x80-0x9F) в тексте не встречаются
+	for i := 32; i < 128; i++ {
+		latin1[i] = 0x80 + rune(i)
+	}
+}
+
+// Decode converts text in the given encoding to UTF-8. Bytes the encoding
+// does not define, and invalid sequences in UTF-8, are kept as \xNN. An
+// empty encoding leaves the data as it is.
+func Decode(encoding string, data []byte) []byte {
+	if encoding == "" || isASCII(data) {
+		return data
+	}
+	if encoding == config.EncodingUTF8 {
+		if utf8.Valid(data) {
+			return data
+		}
+		out := make([]byte, 0, len(data)+8)
+		for len(data) > 0 {
+			r, size := utf8.DecodeRune(data)
+			if r == utf8.RuneError && size == 1 {
+				out = appendEscaped(out, data[0])
+			} else {
+				out = append(out, data[:size]...)
+			}
+			data = data[size:]
+		}
+		return out
+	}
+
+	table, ok := tables[encoding]
+	if !ok {
+		return data
+	}
+	out := make([]byte, 0, 2*len(data))
+	for _, b := range data {
+		switch {
+		case b < utf8.RuneSelf:
+			out = append(out, b)
+		case table[b-0x80] == 0:
+			out = appendEscaped(out, b)
+		default:
+			out = utf8.AppendRune(out, table[b-0x80])
+		}
+	}
+	return out
+}
+
+func appendEscaped(out []byte, b byte) []byte {
+	const digits = "0123456789abcdef"
+	return append(out, '\\', 'x', digits[b>>4], digits[b&0x0F])
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package charset
+
+import (
+	"testing"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		want     string
+	}{
+		{"cp1251", config.EncodingCP1251, []byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2, ' ', 0xA8, 0xB8, ' ', 0xB9}, "Привет Ёё №"},
+		{"cp866", config.EncodingCP866, []byte{0x8F, 0xE0, 0xA8, 0xA2, 0xA5, 0xE2, ' ', 0xF0, 0xF1, ' ', 0xFC}, "Привет Ёё №"},
+		{"cp866 box drawing", config.EncodingCP866, []byte{0xB0, 0xC4, 0xDB, 0xDF}, "░─█▀"},
+		{"cp1251 undefined byte", config.EncodingCP1251, []byte{'t', '=', 0x98, '1'}, `t=\x981`},
+		{"latin1", config.EncodingLatin1, []byte{'c', 'a', 'f', 0xE9, ' ', 0xB0, 'C'}, "café °C"},
+		{"latin1 control byte", config.EncodingLatin1, []byte{'a', 0x85}, `a\x85`},
+		{"valid utf-8", config.EncodingUTF8, []byte("T=21,5 °C"), "T=21,5 °C"},
+		{"invalid utf-8", config.EncodingUTF8, []byte{'a', 0xC3, 'b', 0xFF}, `a\xc3b\xff`},
+		{"no encoding", "", []byte{0xCF, 0xF0}, "\xCF\xF0"},
+		{"ascii", config.EncodingCP866, []byte("T=21.5"), "T=21.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Decode(tt.encoding, tt.data)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Every byte an encoding defines maps to its own character.
+func TestTablesAreOneToOne(t *testing.T) {
+	for name, table := range tables {
+		seen := make(map[rune]int)
+		for i, r := range table {
+			if r == 0 {
+				continue
+			}
+			if r < 0x80 {
+				t.Errorf("%s: 0x%02X maps to ASCII %q", name, 0x80+i, r)
+			}
+			if j, ok := seen[r]; ok {
+				t.Errorf("%s: 0x%02X and 0x%02X both map to %q", name, 0x80+j, 0x80+i, r)
+			}
+			seen[r] = i
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package checksum verifies the integrity checks devices append to their
+// text frames.
+package checksum
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+var (
+	ErrMissing  = errors.New("no checksum")
+	ErrMismatch = errors.New("checksum mismatch")
+)
+
+type algorithm struct {
+	sum    func(data []byte) uint32
+	digits int // hex digits of the written checksum
+}
+
+var algorithms = map[string]algorithm{
+	config.ChecksumNMEA:       {XOR, 2},
+	config.ChecksumXOR:        {XOR, 2},
+	config.ChecksumSum:        {Sum, 2},
+	config.ChecksumCRC8:       {CRC8, 2},
+	config.ChecksumCRC16CCITT: {CRC16CCITT, 4},
+	config.ChecksumCRC32:      {crc32.ChecksumIEEE, 8},
+}
+
+// Verify checks the checksum at the end of the frame and returns the frame
+// without it. Trailing whitespace is ignored.
+func Verify(cfg config.ChecksumConfig, frame []byte) ([]byte, error) {
+	alg, ok := algorithms[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown checksum type %q", cfg.Type)
+	}
+
+	frame = bytes.TrimRight(frame, " \t\r\n")
+	i := bytes.LastIndex(frame, []byte(cfg.Separator))
+	if i < 0 {
+		return nil, ErrMissing
+	}
+	payload, written := frame[:i], strings.TrimSpace(string(frame[i+len(cfg.Separator):]))
+	if len(written) != alg.digits {
+		return nil, fmt.Errorf("%w: %q is not %d hex digits", ErrMissing, written, alg.digits)
+	}
+	want, err := strconv.ParseUint(written, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not %d hex digits", ErrMissing, written, alg.digits)
+	}
+
+	covered := payload
+	if cfg.Skip < len(covered) {
+		covered = covered[cfg.Skip:]
+	} else {
+		covered = nil
+	}
+	if got := alg.sum(covered); got != uint32(want) {
+		return nil, fmt.Errorf("%w: frame has %0*X, computed %0*X", ErrMismatch, alg.digits, want, alg.digits, got)
+	}
+	return payload, nil
+}
+
+// XOR is the NMEA 0183 checksum: all bytes XORed together.
+func XOR(data []byte) uint32 {
+	var x byte
+	for _, b := range data {
+		x ^= b
+	}
+	return uint32(x)
+}
+
+// Sum is the sum of all bytes modulo 256.
+func Sum(data []byte) uint32 {
+	var s byte
+	for _, b := range data {
+		s += b
+	}
+	return uint32(s)
+}
+
+// CRC8 uses the polynomial x^8+x^2+x+1 (0x07) with zero initial value.
+func CRC8(data []byte) uint32 {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint32(crc)
+}
+
+// CRC16CCITT is CRC-16/CCITT-FALSE: polynomial 0x1021, initial value
+// 0xFFFF.
+func CRC16CCITT(data []byte) uint32 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint32(crc)
+}
@@ -0,0 +1,122 @@
+package checksum
+
+import (
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// Known answers for the standard check input "123456789".
+func TestAlgorithms(t *testing.T) {
+	check := []byte("123456789")
+	tests := []struct {
+		name string
+		sum  func([]byte) uint32
+		want uint32
+	}{
+		{"xor", XOR, 0x31},
+		{"sum", Sum, 0xDD},
+		{"crc8", CRC8, 0xF4},
+		{"crc16-ccitt", CRC16CCITT, 0x29B1},
+		{"crc32", crc32.ChecksumIEEE, 0xCBF43926},
+	}
+	for _, tt := range tests {
+		if got := tt.sum(check); got != tt.want {
+			t.Errorf("%s: got %X, want %X", tt.name, got, tt.want)
+		}
+		if got := tt.sum(nil); tt.name != "crc16-ccitt" && got != 0 {
+			t.Errorf("%s of no data: got %X, want 0", tt.name, got)
+		}
+	}
+	if got := CRC16CCITT(nil); got != 0xFFFF {
+		t.Errorf("crc16-ccitt of no data: got %X, want FFFF", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.ChecksumConfig
+		frame   string
+		payload string
+		err     error // nil, ErrMissing or ErrMismatch
+	}{
+		{
+			name:    "nmea",
+			cfg:     config.ChecksumConfig{Type: config.ChecksumNMEA},
+			frame:   "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n",
+			payload: "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		},
+		{
+			name:  "nmea mismatch",
+			cfg:   config.ChecksumConfig{Type: config.ChecksumNMEA},
+			frame: "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*48",
+			err:   ErrMismatch,
+		},
+		{
+			name:    "crc16 with a separator",
+			cfg:     config.ChecksumConfig{Type: config.ChecksumCRC16CCITT, Separator: ";"},
+			frame:   "123456789;29b1",
+			payload: "123456789",
+		},
+		{
+			name:    "crc32",
+			cfg:     config.ChecksumConfig{Type: config.ChecksumCRC32},
+			frame:   "123456789*CBF43926",
+			payload: "123456789",
+		},
+		{
+			name:    "skipped prefix",
+			cfg:     config.ChecksumConfig{Type: config.ChecksumCRC8, Skip: 2},
+			frame:   "##123456789*F4",
+			payload: "##123456789",
+		},
+		{
+			name:  "no separator",
+			cfg:   config.ChecksumConfig{Type: config.ChecksumXOR},
+			frame: "123456789",
+			err:   ErrMissing,
+		},
+		{
+			name:  "wrong number of digits",
+			cfg:   config.ChecksumConfig{Type: config.ChecksumCRC16CCITT},
+			frame: "123456789*29B",
+			err:   ErrMissing,
+		},
+		{
+			name:  "not hex",
+			cfg:   config.ChecksumConfig{Type: config.ChecksumSum},
+			frame: "123456789*ZZ",
+			err:   ErrMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Normalize(); err != nil {
+				t.Fatal(err)
+			}
+			payload, err := Verify(tt.cfg, []byte(tt.frame))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(payload) != tt.payload {
+				t.Fatalf("payload %q, want %q", payload, tt.payload)
+			}
+		})
+	}
+}
+
+func TestVerifyUnknownType(t *testing.T) {
+	if _, err := Verify(config.ChecksumConfig{Type: "md5"}, []byte("a*00")); err == nil {
+		t.Fatal("unknown checksum type accepted")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/physicist2018/gomodserial-v1/internal/domain"
 	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
@@ -28,6 +29,11 @@ func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// SQLite allows a single writer. Several ports save measurements at the
+	// same time, so all access goes through one connection; this also keeps
+	// the pragmas below in effect for every query.
+	db.SetMaxOpenConns(1)
+
 	// Enable foreign keys
 	if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil {
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
@@ -38,6 +44,10 @@ func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
 		return nil, fmt.Errorf("failed to initialize tables: %w", err)
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
 	return &SQLiteRepository{db: db}, nil
 }
 
@@ -78,10 +88,182 @@ func checkAndCreateTables(db *sql.DB) error {
 	return nil
 }
 
+// migrate brings databases created by older versions up to date. Every step
+// must be safe to run repeatedly.
+func migrate(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "measurements", "raw", "BLOB"); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS measurement_values (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			measurement_id INTEGER NOT NULL,
+			channel TEXT NOT NULL,
+			value REAL NOT NULL,
+			FOREIGN KEY (measurement_id) REFERENCES measurements (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_measurement_values_measurement_id ON measurement_values (measurement_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create measurement_values table: %w", err)
+	}
+
+	if err := addColumnIfMissing(db, "measurement_values", "type", "TEXT NOT NULL DEFAULT 'numeric'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "measurement_values", "text_value", "TEXT"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(db, "measurements", "parse_error", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "measurements", "device_time", "DATETIME"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "measurements", "gps_time", "DATETIME"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_measurements_device_time ON measurements (experiment_id, device_time)"); err != nil {
+		return fmt.Errorf("failed to create device time index: %w", err)
+	}
+	if err := addColumnIfMissing(db, "measurements", "sequence", "INTEGER"); err != nil {
+		return err
+	}
+	for _, column := range []string{"parser_type", "parser_separator", "parser_pattern", "port", "instrument"} {
+		if err := addColumnIfMissing(db, "experiments", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS experiment_channels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			experiment_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			unit TEXT NOT NULL,
+			type TEXT NOT NULL,
+			FOREIGN KEY (experiment_id) REFERENCES experiments (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_experiment_channels_experiment_id ON experiment_channels (experiment_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create experiment_channels table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS experiment_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			experiment_id INTEGER NOT NULL,
+			port TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			message TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME NOT NULL,
+			FOREIGN KEY (experiment_id) REFERENCES experiments (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_experiment_events_experiment_id ON experiment_events (experiment_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create experiment_events table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS quarantine (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			experiment_id INTEGER NOT NULL,
+			port TEXT NOT NULL,
+			raw BLOB NOT NULL,
+			reason TEXT NOT NULL,
+			received_at DATETIME NOT NULL,
+			FOREIGN KEY (experiment_id) REFERENCES experiments (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_quarantine_experiment_id ON quarantine (experiment_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create quarantine table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS experiment_stats (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			experiment_id INTEGER NOT NULL,
+			port TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME NOT NULL,
+			bytes_read INTEGER NOT NULL,
+			frames INTEGER NOT NULL,
+			parsed INTEGER NOT NULL,
+			parse_errors INTEGER NOT NULL,
+			frame_errors INTEGER NOT NULL,
+			quarantined INTEGER NOT NULL,
+			db_errors INTEGER NOT NULL,
+			reconnects INTEGER NOT NULL,
+			last_frame_at DATETIME,
+			FOREIGN KEY (experiment_id) REFERENCES experiments (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_experiment_stats_experiment_id ON experiment_stats (experiment_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create experiment_stats table: %w", err)
+	}
+
+	return nil
+}
+
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	domain.DomainLogger.Printf("Added column %s.%s", table, column)
+	return nil
+}
+
 func (r *SQLiteRepository) CreateExperiment(ctx context.Context, experiment *entity.Experiment) (int, error) {
-	res, err := r.db.ExecContext(ctx,
-		"INSERT INTO experiments (name, description, created_at) VALUES (?, ?, ?)",
-		experiment.Name, experiment.Description, experiment.CreatedAt,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO experiments (name, description, created_at, port, parser_type, parser_separator, parser_pattern)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		experiment.Name, experiment.Description, experiment.CreatedAt, experiment.Port,
+		experiment.Parser.Type, experiment.Parser.Separator, experiment.Parser.Pattern,
 	)
 	if err != nil {
 		return 0, err
@@ -92,11 +274,25 @@ func (r *SQLiteRepository) CreateExperiment(ctx context.Context, experiment *ent
 		return 0, err
 	}
 
+	for _, ch := range experiment.Channels {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO experiment_channels (experiment_id, position, name, unit, type) VALUES (?, ?, ?, ?, ?)",
+			id, ch.Position, ch.Name, ch.Unit, ch.Type,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(id), nil
 }
 
 func (r *SQLiteRepository) GetAllExperiments(ctx context.Context) ([]entity.Experiment, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, name, description, created_at FROM experiments ORDER BY created_at DESC")
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, description, created_at, port FROM experiments ORDER BY created_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +301,7 @@ func (r *SQLiteRepository) GetAllExperiments(ctx context.Context) ([]entity.Expe
 	var experiments []entity.Experiment
 	for rows.Next() {
 		var exp entity.Experiment
-		if err := rows.Scan(&exp.ID, &exp.Name, &exp.Description, &exp.CreatedAt); err != nil {
+		if err := rows.Scan(&exp.ID, &exp.Name, &exp.Description, &exp.CreatedAt, &exp.Port); err != nil {
 			return nil, err
 		}
 		experiments = append(experiments, exp)
@@ -117,9 +313,16 @@ func (r *SQLiteRepository) GetAllExperiments(ctx context.Context) ([]entity.Expe
 func (r *SQLiteRepository) GetExperimentByID(ctx context.Context, id int) (*entity.Experiment, error) {
 	var exp entity.Experiment
 	err := r.db.QueryRowContext(ctx,
-		"SELECT id, name, description, created_at FROM experiments WHERE id = ?",
+		`SELECT id, name, description, created_at, port, parser_type, parser_separator, parser_pattern, instrument
+		FROM experiments WHERE id = ?`,
 		id,
-	).Scan(&exp.ID, &exp.Name, &exp.Description, &exp.CreatedAt)
+	).Scan(&exp.ID, &exp.Name, &exp.Description, &exp.CreatedAt, &exp.Port,
+		&exp.Parser.Type, &exp.Parser.Separator, &exp.Parser.Pattern, &exp.Instrument)
+	if err != nil {
+		return nil, err
+	}
+
+	exp.Channels, err = r.GetChannelsByExperimentID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -127,17 +330,131 @@ func (r *SQLiteRepository) GetExperimentByID(ctx context.Context, id int) (*enti
 	return &exp, nil
 }
 
+func (r *SQLiteRepository) SetExperimentInstrument(ctx context.Context, id int, instrument string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE experiments SET instrument = ? WHERE id = ?", instrument, id)
+	return err
+}
+
+// DeleteExperiment removes the experiment; its channels, measurements and
+// events go with it.
+func (r *SQLiteRepository) DeleteExperiment(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM experiments WHERE id = ?", id)
+	return err
+}
+
+func (r *SQLiteRepository) SaveSessionStats(ctx context.Context, stats *entity.SessionStats) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO experiment_stats (experiment_id, port, started_at, ended_at, bytes_read, frames, parsed,
+			parse_errors, frame_errors, quarantined, db_errors, reconnects, last_frame_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		stats.ExperimentID, stats.Port, stats.StartedAt, stats.EndedAt, stats.BytesRead, stats.Frames, stats.Parsed,
+		stats.ParseErrors, stats.FrameErrors, stats.Quarantined, stats.DBErrors, stats.Reconnects, nullTime(stats.LastFrameAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	stats.ID = int(id)
+	return nil
+}
+
+func (r *SQLiteRepository) GetSessionStats(ctx context.Context, experimentID int) ([]entity.SessionStats, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, experiment_id, port, started_at, ended_at, bytes_read, frames, parsed,
+			parse_errors, frame_errors, quarantined, db_errors, reconnects, last_frame_at
+		FROM experiment_stats WHERE experiment_id = ? ORDER BY started_at, id`,
+		experimentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []entity.SessionStats
+	for rows.Next() {
+		var s entity.SessionStats
+		var lastFrame sql.NullTime
+		if err := rows.Scan(&s.ID, &s.ExperimentID, &s.Port, &s.StartedAt, &s.EndedAt, &s.BytesRead, &s.Frames, &s.Parsed,
+			&s.ParseErrors, &s.FrameErrors, &s.Quarantined, &s.DBErrors, &s.Reconnects, &lastFrame); err != nil {
+			return nil, err
+		}
+		if lastFrame.Valid {
+			s.LastFrameAt = &lastFrame.Time
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+func (r *SQLiteRepository) GetChannelsByExperimentID(ctx context.Context, experimentID int) ([]entity.Channel, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, experiment_id, position, name, unit, type FROM experiment_channels WHERE experiment_id = ? ORDER BY position",
+		experimentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []entity.Channel
+	for rows.Next() {
+		var ch entity.Channel
+		if err := rows.Scan(&ch.ID, &ch.ExperimentID, &ch.Position, &ch.Name, &ch.Unit, &ch.Type); err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+
+	return channels, rows.Err()
+}
+
 func (r *SQLiteRepository) CreateMeasurement(ctx context.Context, measurement *entity.Measurement) error {
-	_, err := r.db.ExecContext(ctx,
-		"INSERT INTO measurements (experiment_id, value, timestamp) VALUES (?, ?, ?)",
-		measurement.ExperimentID, measurement.Value, measurement.Timestamp,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO measurements (experiment_id, value, raw, parse_error, timestamp, device_time, gps_time, sequence) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		measurement.ExperimentID, measurement.Value, measurement.Raw, nullString(measurement.ParseError), measurement.Timestamp,
+		nullTime(measurement.DeviceTime), nullTime(measurement.GPSTime), nullInt64(measurement.Sequence),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range measurement.Values {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO measurement_values (measurement_id, channel, type, value, text_value) VALUES (?, ?, ?, ?, ?)",
+			id, v.Channel, v.Type, v.Value, v.Text,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	measurement.ID = int(id)
+	return nil
 }
 
 func (r *SQLiteRepository) GetMeasurementsByExperimentID(ctx context.Context, experimentID int) ([]entity.Measurement, error) {
 	rows, err := r.db.QueryContext(ctx,
-		"SELECT id, experiment_id, value, timestamp FROM measurements WHERE experiment_id = ? ORDER BY timestamp",
+		`SELECT id, experiment_id, value, raw, COALESCE(parse_error, ''), timestamp, device_time, gps_time, sequence
+		FROM measurements WHERE experiment_id = ? ORDER BY timestamp`,
 		experimentID,
 	)
 	if err != nil {
@@ -146,15 +463,157 @@ func (r *SQLiteRepository) GetMeasurementsByExperimentID(ctx context.Context, ex
 	defer rows.Close()
 
 	var measurements []entity.Measurement
+	index := make(map[int]int)
 	for rows.Next() {
-		var m entity.Measurement
-		if err := rows.Scan(&m.ID, &m.ExperimentID, &m.Value, &m.Timestamp); err != nil {
+		var (
+			m          entity.Measurement
+			deviceTime sql.NullTime
+			gpsTime    sql.NullTime
+			sequence   sql.NullInt64
+		)
+		if err := rows.Scan(&m.ID, &m.ExperimentID, &m.Value, &m.Raw, &m.ParseError, &m.Timestamp, &deviceTime, &gpsTime, &sequence); err != nil {
 			return nil, err
 		}
+		if deviceTime.Valid {
+			m.DeviceTime = &deviceTime.Time
+		}
+		if gpsTime.Valid {
+			m.GPSTime = &gpsTime.Time
+		}
+		if sequence.Valid {
+			m.Sequence = &sequence.Int64
+		}
+		index[m.ID] = len(measurements)
 		measurements = append(measurements, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	valueRows, err := r.db.QueryContext(ctx, `
+		SELECT v.measurement_id, v.channel, v.type, v.value, COALESCE(v.text_value, '')
+		FROM measurement_values v
+		JOIN measurements m ON m.id = v.measurement_id
+		WHERE m.experiment_id = ?
+		ORDER BY v.id`,
+		experimentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer valueRows.Close()
+
+	for valueRows.Next() {
+		var (
+			measurementID int
+			v             entity.ChannelValue
+		)
+		if err := valueRows.Scan(&measurementID, &v.Channel, &v.Type, &v.Value, &v.Text); err != nil {
+			return nil, err
+		}
+		if i, ok := index[measurementID]; ok {
+			measurements[i].Values = append(measurements[i].Values, v)
+		}
+	}
+
+	return measurements, valueRows.Err()
+}
+
+func (r *SQLiteRepository) CreateEvent(ctx context.Context, event *entity.Event) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO experiment_events (experiment_id, port, kind, message, started_at, ended_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		event.ExperimentID, event.Port, event.Kind, event.Message, event.StartedAt, event.EndedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	event.ID = int(id)
+	return nil
+}
+
+func (r *SQLiteRepository) GetEventsByExperimentID(ctx context.Context, experimentID int) ([]entity.Event, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, experiment_id, port, kind, message, started_at, ended_at
+		FROM experiment_events WHERE experiment_id = ? ORDER BY started_at, id`,
+		experimentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []entity.Event
+	for rows.Next() {
+		var e entity.Event
+		if err := rows.Scan(&e.ID, &e.ExperimentID, &e.Port, &e.Kind, &e.Message, &e.StartedAt, &e.EndedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *SQLiteRepository) QuarantineFrame(ctx context.Context, frame *entity.QuarantinedFrame) error {
+	res, err := r.db.ExecContext(ctx,
+		"INSERT INTO quarantine (experiment_id, port, raw, reason, received_at) VALUES (?, ?, ?, ?, ?)",
+		frame.ExperimentID, frame.Port, frame.Raw, frame.Reason, frame.ReceivedAt,
+	)
+	if err != nil {
+		return err
+	}
 
-	return measurements, nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	frame.ID = int(id)
+	return nil
+}
+
+func (r *SQLiteRepository) GetQuarantinedFrames(ctx context.Context, experimentID int) ([]entity.QuarantinedFrame, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, experiment_id, port, raw, reason, received_at
+		FROM quarantine WHERE experiment_id = ? ORDER BY received_at, id`,
+		experimentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var frames []entity.QuarantinedFrame
+	for rows.Next() {
+		var f entity.QuarantinedFrame
+		if err := rows.Scan(&f.ID, &f.ExperimentID, &f.Port, &f.Raw, &f.Reason, &f.ReceivedAt); err != nil {
+			return nil, err
+		}
+		frames = append(frames, f)
+	}
+	return frames, rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt64(n *int64) sql.NullInt64 {
+	if n == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *n, Valid: true}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
 }
 
 func (r *SQLiteRepository) Close() error {
@@ -0,0 +1,114 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// Block is one read request of a poll cycle.
+type Block struct {
+	Slave    byte
+	Function byte
+	Address  uint16
+	Count    uint16
+}
+
+func (b Block) String() string {
+	table := config.ModbusHolding
+	if b.Function == FuncReadInputRegisters {
+		table = config.ModbusInput
+	}
+	return fmt.Sprintf("slave %d %s %d-%d", b.Slave, table, b.Address, int(b.Address)+int(b.Count)-1)
+}
+
+// placement locates a register value in the data of a block.
+type placement struct {
+	block  int
+	offset int // in bytes
+	words  int
+	swap   bool // low word first
+}
+
+// Plan reads a register map with as few requests as possible and assembles
+// the answers into one record: the values in configuration order, each
+// big-endian, as described by config.ModbusConfig.Layout.
+type Plan struct {
+	Blocks []Block
+	fields []placement
+}
+
+// NewPlan merges registers that are adjacent or overlap on the same slave
+// and table into one request. Registers are never read across a gap, since
+// the slave may not implement the addresses in between.
+func NewPlan(registers []config.ModbusRegister) *Plan {
+	order := make([]int, len(registers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := registers[order[a]], registers[order[b]]
+		if ra.Slave != rb.Slave {
+			return ra.Slave < rb.Slave
+		}
+		if ra.Table != rb.Table {
+			return ra.Table < rb.Table
+		}
+		return ra.Address < rb.Address
+	})
+
+	p := &Plan{fields: make([]placement, len(registers))}
+	for _, i := range order {
+		r := registers[i]
+		slave, function := byte(r.Slave), tableFunction(r.Table)
+		end := r.Address + r.Words()
+
+		n := len(p.Blocks) - 1
+		if n < 0 || !p.extends(n, slave, function, r.Address, end) {
+			p.Blocks = append(p.Blocks, Block{Slave: slave, Function: function, Address: uint16(r.Address)})
+			n++
+		}
+		b := &p.Blocks[n]
+		if blockEnd := int(b.Address) + int(b.Count); end > blockEnd {
+			b.Count = uint16(end - int(b.Address))
+		}
+		p.fields[i] = placement{
+			block:  n,
+			offset: (r.Address - int(b.Address)) * 2,
+			words:  r.Words(),
+			swap:   r.WordOrder == config.WordOrderLittle,
+		}
+	}
+	return p
+}
+
+func (p *Plan) extends(n int, slave, function byte, start, end int) bool {
+	b := p.Blocks[n]
+	blockEnd := int(b.Address) + int(b.Count)
+	return b.Slave == slave && b.Function == function &&
+		start <= blockEnd && end-int(b.Address) <= MaxRegisters
+}
+
+func tableFunction(table string) byte {
+	if table == config.ModbusInput {
+		return FuncReadInputRegisters
+	}
+	return FuncReadHoldingRegisters
+}
+
+// Record assembles the record from the register data of every block.
+func (p *Plan) Record(data [][]byte) []byte {
+	var record []byte
+	for _, f := range p.fields {
+		value := data[f.block][f.offset : f.offset+f.words*2]
+		if !f.swap {
+			record = append(record, value...)
+			continue
+		}
+		for w := f.words - 1; w >= 0; w-- {
+			record = append(record, value[w*2:w*2+2]...)
+		}
+	}
+	return record
+}
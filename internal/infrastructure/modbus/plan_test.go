@@ -0,0 +1,113 @@
+package modbus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func holding(slave, address int, typ string) config.ModbusRegister {
+	return config.ModbusRegister{Slave: slave, Table: config.ModbusHolding, Address: address, Type: typ}
+}
+
+func TestNewPlan(t *testing.T) {
+	var chain []config.ModbusRegister
+	for a := 0; a <= MaxRegisters; a++ {
+		chain = append(chain, holding(1, a, "uint16"))
+	}
+	input := holding(1, 1, "uint16")
+	input.Table = config.ModbusInput
+
+	tests := []struct {
+		name      string
+		registers []config.ModbusRegister
+		blocks    []Block
+	}{
+		{
+			name:      "adjacent registers are merged",
+			registers: []config.ModbusRegister{holding(1, 0, "uint16"), holding(1, 1, "float32")},
+			blocks:    []Block{{Slave: 1, Function: FuncReadHoldingRegisters, Address: 0, Count: 3}},
+		},
+		{
+			name:      "configuration order does not matter",
+			registers: []config.ModbusRegister{holding(1, 1, "float32"), holding(1, 0, "uint16")},
+			blocks:    []Block{{Slave: 1, Function: FuncReadHoldingRegisters, Address: 0, Count: 3}},
+		},
+		{
+			name:      "overlapping registers are merged",
+			registers: []config.ModbusRegister{holding(1, 10, "uint32"), holding(1, 11, "uint16")},
+			blocks:    []Block{{Slave: 1, Function: FuncReadHoldingRegisters, Address: 10, Count: 2}},
+		},
+		{
+			name:      "a gap is not read",
+			registers: []config.ModbusRegister{holding(1, 0, "uint16"), holding(1, 2, "uint16")},
+			blocks: []Block{
+				{Slave: 1, Function: FuncReadHoldingRegisters, Address: 0, Count: 1},
+				{Slave: 1, Function: FuncReadHoldingRegisters, Address: 2, Count: 1},
+			},
+		},
+		{
+			name:      "slaves and tables are read separately",
+			registers: []config.ModbusRegister{holding(2, 0, "uint16"), holding(1, 0, "uint16"), input},
+			blocks: []Block{
+				{Slave: 1, Function: FuncReadHoldingRegisters, Address: 0, Count: 1},
+				{Slave: 1, Function: FuncReadInputRegisters, Address: 1, Count: 1},
+				{Slave: 2, Function: FuncReadHoldingRegisters, Address: 0, Count: 1},
+			},
+		},
+		{
+			name:      "a request is limited to MaxRegisters",
+			registers: chain,
+			blocks: []Block{
+				{Slave: 1, Function: FuncReadHoldingRegisters, Address: 0, Count: MaxRegisters},
+				{Slave: 1, Function: FuncReadHoldingRegisters, Address: MaxRegisters, Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewPlan(tt.registers).Blocks; !reflect.DeepEqual(got, tt.blocks) {
+				t.Errorf("got %v, want %v", got, tt.blocks)
+			}
+		})
+	}
+}
+
+func TestPlanRecord(t *testing.T) {
+	little := holding(1, 0, "uint32")
+	little.WordOrder = config.WordOrderLittle
+	registers := []config.ModbusRegister{
+		holding(1, 2, "uint16"),
+		little,
+		holding(1, 1, "uint32"),
+		holding(2, 7, "uint16"),
+	}
+	p := NewPlan(registers)
+	if len(p.Blocks) != 2 {
+		t.Fatalf("got blocks %v", p.Blocks)
+	}
+
+	record := p.Record([][]byte{
+		{0x00, 0x01, 0x00, 0x02, 0x00, 0x03},
+		{0xAB, 0xCD},
+	})
+	want := []byte{
+		0x00, 0x03, // register 2
+		0x00, 0x02, 0x00, 0x01, // registers 0-1, low word first
+		0x00, 0x02, 0x00, 0x03, // registers 1-2
+		0xAB, 0xCD, // slave 2
+	}
+	if !bytes.Equal(record, want) {
+		t.Fatalf("got % X, want % X", record, want)
+	}
+}
+
+func TestBlockString(t *testing.T) {
+	b := Block{Slave: 3, Function: FuncReadInputRegisters, Address: 100, Count: 4}
+	if got := b.String(); got != "slave 3 input 100-103" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,139 @@
+// Package modbus implements the parts of Modbus RTU a master needs to read
+// registers: request encoding, response checking and frame timing.
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	FuncReadHoldingRegisters = 0x03
+	FuncReadInputRegisters   = 0x04
+
+	// MaxRegisters is the most registers one read request may ask for.
+	MaxRegisters = 125
+
+	exceptionFlag = 0x80
+)
+
+// CRC16 computes the Modbus CRC (polynomial 0xA001, initial value 0xFFFF).
+// It is sent low byte first.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func appendCRC(frame []byte) []byte {
+	return binary.LittleEndian.AppendUint16(frame, CRC16(frame))
+}
+
+// CheckCRC reports whether the last two bytes of the frame are its CRC.
+func CheckCRC(frame []byte) bool {
+	if len(frame) < 4 {
+		return false
+	}
+	n := len(frame) - 2
+	return binary.LittleEndian.Uint16(frame[n:]) == CRC16(frame[:n])
+}
+
+// ReadRequest encodes a request to read count registers starting at
+// address.
+func ReadRequest(slave, function byte, address, count uint16) []byte {
+	frame := []byte{slave, function, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:], address)
+	binary.BigEndian.PutUint16(frame[4:], count)
+	return appendCRC(frame)
+}
+
+// ResponseLength returns the length of the response frame that starts with
+// header, once enough of the header (at most three bytes) is known.
+func ResponseLength(header []byte) (length int, ok bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	if header[1]&exceptionFlag != 0 {
+		return 5, true
+	}
+	if len(header) < 3 {
+		return 0, false
+	}
+	return 5 + int(header[2]), true
+}
+
+// ExceptionError is an exception response of a slave.
+type ExceptionError struct {
+	Function byte
+	Code     byte
+}
+
+func (e *ExceptionError) Error() string {
+	return fmt.Sprintf("exception 0x%02X (%s) for function 0x%02X", e.Code, exceptionText(e.Code), e.Function)
+}
+
+func exceptionText(code byte) string {
+	switch code {
+	case 0x01:
+		return "illegal function"
+	case 0x02:
+		return "illegal data address"
+	case 0x03:
+		return "illegal data value"
+	case 0x04:
+		return "slave device failure"
+	case 0x05:
+		return "acknowledge"
+	case 0x06:
+		return "slave device busy"
+	case 0x0B:
+		return "gateway target device failed to respond"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// ParseReadResponse checks a response to ReadRequest and returns the
+// register data, two bytes per register, big-endian.
+func ParseReadResponse(frame []byte, slave, function byte, count uint16) ([]byte, error) {
+	if !CheckCRC(frame) {
+		return nil, fmt.Errorf("%w: CRC mismatch", ErrUnexpectedResponse)
+	}
+	if frame[0] != slave {
+		return nil, fmt.Errorf("%w: from slave %d instead of %d", ErrUnexpectedResponse, frame[0], slave)
+	}
+	if frame[1] == function|exceptionFlag {
+		return nil, &ExceptionError{Function: function, Code: frame[2]}
+	}
+	if frame[1] != function {
+		return nil, fmt.Errorf("%w: function 0x%02X instead of 0x%02X", ErrUnexpectedResponse, frame[1], function)
+	}
+	data := frame[3 : len(frame)-2]
+	if int(frame[2]) != len(data) || len(data) != int(count)*2 {
+		return nil, fmt.Errorf("%w: %d data bytes instead of %d", ErrUnexpectedResponse, len(data), count*2)
+	}
+	return data, nil
+}
+
+// FrameGap returns the silent interval of 3.5 characters that must separate
+// RTU frames. Above 19200 baud the standard fixes it at 1.75 ms.
+func FrameGap(baudRate int) time.Duration {
+	if baudRate <= 0 || baudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	// 11 bits per character: start, 8 data, parity or second stop, stop
+	return time.Duration(3.5 * 11 * float64(time.Second) / float64(baudRate))
+}
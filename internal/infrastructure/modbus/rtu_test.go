@@ -0,0 +1,103 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint16
+	}{
+		{[]byte("123456789"), 0x4B37},
+		{[]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}, 0xCDC5},
+		{nil, 0xFFFF},
+	}
+	for _, tt := range tests {
+		if got := CRC16(tt.data); got != tt.want {
+			t.Errorf("CRC16(% X) = %04X, want %04X", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	got := ReadRequest(1, FuncReadHoldingRegisters, 0, 10)
+	want := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got % X, want % X", got, want)
+	}
+	if !CheckCRC(got) {
+		t.Fatal("CRC of the request does not check")
+	}
+	got[3] ^= 1
+	if CheckCRC(got) {
+		t.Fatal("CRC of a damaged request checks")
+	}
+}
+
+func TestResponseLength(t *testing.T) {
+	tests := []struct {
+		header []byte
+		length int
+		ok     bool
+	}{
+		{[]byte{0x01}, 0, false},
+		{[]byte{0x01, 0x03}, 0, false},
+		{[]byte{0x01, 0x03, 0x04}, 9, true},
+		{[]byte{0x01, 0x83}, 5, true},
+	}
+	for _, tt := range tests {
+		length, ok := ResponseLength(tt.header)
+		if length != tt.length || ok != tt.ok {
+			t.Errorf("ResponseLength(% X) = %d, %v, want %d, %v", tt.header, length, ok, tt.length, tt.ok)
+		}
+	}
+}
+
+func TestParseReadResponse(t *testing.T) {
+	response := appendCRC([]byte{0x01, 0x03, 0x04, 0x00, 0x2A, 0x01, 0x00})
+	exception := appendCRC([]byte{0x01, 0x83, 0x02})
+	damaged := append([]byte{}, response...)
+	damaged[4] ^= 1
+
+	data, err := ParseReadResponse(response, 1, FuncReadHoldingRegisters, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x00, 0x2A, 0x01, 0x00}) {
+		t.Fatalf("data % X", data)
+	}
+
+	var exc *ExceptionError
+	if _, err := ParseReadResponse(exception, 1, FuncReadHoldingRegisters, 2); !errors.As(err, &exc) || exc.Code != 0x02 {
+		t.Fatalf("got error %v, want exception 2", err)
+	}
+	for _, tt := range []struct {
+		name     string
+		frame    []byte
+		slave    byte
+		function byte
+		count    uint16
+	}{
+		{"damaged", damaged, 1, FuncReadHoldingRegisters, 2},
+		{"other slave", response, 2, FuncReadHoldingRegisters, 2},
+		{"other function", response, 1, FuncReadInputRegisters, 2},
+		{"other count", response, 1, FuncReadHoldingRegisters, 3},
+	} {
+		if _, err := ParseReadResponse(tt.frame, tt.slave, tt.function, tt.count); !errors.Is(err, ErrUnexpectedResponse) {
+			t.Errorf("%s: got error %v, want ErrUnexpectedResponse", tt.name, err)
+		}
+	}
+}
+
+func TestFrameGap(t *testing.T) {
+	if got := FrameGap(9600); got != 4010416*time.Nanosecond {
+		t.Errorf("gap at 9600 baud is %s", got)
+	}
+	if got := FrameGap(115200); got != 1750*time.Microsecond {
+		t.Errorf("gap at 115200 baud is %s", got)
+	}
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserCSV, func(cfg config.ParserConfig, channels []entity.Channel) (Parser, error) {
+		comma := ','
+		if cfg.Separator != "" {
+			if utf8.RuneCountInString(cfg.Separator) != 1 {
+				return nil, fmt.Errorf("csv separator must be a single character, got %q", cfg.Separator)
+			}
+			comma, _ = utf8.DecodeRuneInString(cfg.Separator)
+		}
+		return &CSV{channels: channels, comma: comma}, nil
+	})
+}
+
+// CSV parses one line of comma separated values, with quoting.
+type CSV struct {
+	channels []entity.Channel
+	comma    rune
+}
+
+func (p *CSV) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimSpace(string(frame))))
+	r.Comma = p.comma
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	fields, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	return positionalValues(p.channels, fields)
+}
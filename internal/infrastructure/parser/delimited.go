@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserDelimited, func(cfg config.ParserConfig, channels []entity.Channel) (Parser, error) {
+		return NewDelimited(channels, cfg.Separator), nil
+	})
+}
+
+// Delimited splits a text line into fields and assigns them to the
+// channels by position. Without a separator fields are split on commas,
+// semicolons, tabs and spaces.
+type Delimited struct {
+	channels  []entity.Channel
+	separator string
+}
+
+func NewDelimited(channels []entity.Channel, separator string) *Delimited {
+	return &Delimited{channels: channels, separator: separator}
+}
+
+func (p *Delimited) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	line := strings.TrimSpace(string(frame))
+
+	var fields []string
+	if p.separator == "" {
+		fields = strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == '\t' || r == ' '
+		})
+	} else {
+		fields = strings.Split(line, p.separator)
+	}
+
+	return positionalValues(p.channels, fields)
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// DeviceTime returns the time the device gave a measurement, read from the
+// configured channel of the decoded values. A layout without a date takes
+// the date of ref, the time the frame was received.
+func DeviceTime(cfg config.DeviceTimeConfig, values []entity.ChannelValue, ref time.Time) (time.Time, error) {
+	var (
+		v     entity.ChannelValue
+		found bool
+	)
+	for _, cv := range values {
+		if cv.Channel == cfg.Channel {
+			v, found = cv, true
+			break
+		}
+	}
+	if !found {
+		return time.Time{}, fmt.Errorf("no channel %q", cfg.Channel)
+	}
+
+	text := v.Text
+	if v.Type != entity.ChannelText {
+		text = strconv.FormatFloat(v.Value, 'f', -1, 64)
+	}
+	text = strings.TrimSpace(text)
+
+	switch cfg.Format {
+	case config.DeviceTimeUnix, config.DeviceTimeUnixMs:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a number", text)
+		}
+		if cfg.Format == config.DeviceTimeUnixMs {
+			n /= 1000
+		}
+		sec, frac := math.Modf(n)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	case config.DeviceTimeRFC3339:
+		return time.Parse(time.RFC3339Nano, text)
+	}
+
+	t, err := time.ParseInLocation(cfg.Format, text, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.Year() == 0 {
+		t = withDate(t, ref.In(t.Location()))
+	}
+	return t, nil
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserJSON, func(_ config.ParserConfig, channels []entity.Channel) (Parser, error) {
+		return &JSON{channels: channels}, nil
+	})
+}
+
+// JSON parses one JSON object per line. Nested objects are flattened with
+// dots, so {"env": {"t": 1}} yields the channel "env.t".
+type JSON struct {
+	channels []entity.Channel
+}
+
+func (p *JSON) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	var obj map[string]any
+	if err := json.Unmarshal(frame, &obj); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %w", err)
+	}
+
+	flat := make(map[string]any)
+	flatten("", obj, flat)
+
+	names := make([]string, 0, len(flat))
+	for name := range flat {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(p.channels) == 0 {
+		values := make([]entity.ChannelValue, 0, len(names))
+		for _, name := range names {
+			switch v := flat[name].(type) {
+			case float64:
+				values = append(values, entity.NumericValue(name, v))
+			case bool:
+				values = append(values, entity.BoolValue(name, v))
+			case string:
+				values = append(values, entity.TextValue(name, v))
+			}
+		}
+		return values, nil
+	}
+
+	fields := make([]string, len(names))
+	for i, name := range names {
+		switch v := flat[name].(type) {
+		case float64:
+			fields[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			fields[i] = strconv.FormatBool(v)
+		case string:
+			fields[i] = v
+		}
+	}
+	return namedValues(p.channels, names, fields)
+}
+
+func flatten(prefix string, obj map[string]any, out map[string]any) {
+	for key, v := range obj {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(key, v, out)
+		case nil, []any:
+			// Arrays and nulls have no channel representation.
+		default:
+			out[key] = v
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserKeyValue, func(_ config.ParserConfig, channels []entity.Channel) (Parser, error) {
+		return &KeyValue{channels: channels}, nil
+	})
+}
+
+// KeyValue parses lines like "T=23.4 H=45" or "T: 23.4; H: 45". Keys are
+// matched against channel names.
+type KeyValue struct {
+	channels []entity.Channel
+}
+
+func (p *KeyValue) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	pairs := strings.FieldsFunc(strings.TrimSpace(string(frame)), func(r rune) bool {
+		return r == ',' || r == ';' || r == '\t' || r == ' '
+	})
+
+	// "T: 23.4" is split into "T:" and "23.4" above, join them back.
+	var names, fields []string
+	for i := 0; i < len(pairs); i++ {
+		pair := pairs[i]
+		if strings.HasSuffix(pair, ":") || strings.HasSuffix(pair, "=") {
+			if i+1 < len(pairs) {
+				i++
+				pair += pairs[i]
+			}
+		}
+
+		sep := strings.IndexAny(pair, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		names = append(names, pair[:sep])
+		fields = append(fields, pair[sep+1:])
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no key=value pairs found")
+	}
+
+	return namedValues(p.channels, names, fields)
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserBinary, func(cfg config.ParserConfig, _ []entity.Channel) (Parser, error) {
+		return NewLayout(cfg.Layout)
+	})
+}
+
+// Layout decodes packed binary records into named numeric channels.
+type Layout struct {
+	fields []config.LayoutField
+	size   int
+}
+
+func NewLayout(fields []config.LayoutField) (*Layout, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("layout has no fields")
+	}
+	return &Layout{
+		fields: fields,
+		size:   config.LayoutSize(fields),
+	}, nil
+}
+
+// Size returns the record size in bytes.
+func (l *Layout) Size() int {
+	return l.size
+}
+
+func (l *Layout) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	if len(frame) != l.size {
+		return nil, fmt.Errorf("frame is %d bytes, layout expects %d", len(frame), l.size)
+	}
+
+	values := make([]entity.ChannelValue, 0, len(l.fields))
+	pos := 0
+	for _, f := range l.fields {
+		size := f.Size()
+		data := frame[pos : pos+size]
+		pos += size
+
+		if f.IsSkip() {
+			continue
+		}
+
+		var order binary.ByteOrder = binary.LittleEndian
+		if f.Endian == config.EndianBig {
+			order = binary.BigEndian
+		}
+
+		raw, err := decodeField(f.Type, data, order)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", f.Name, err)
+		}
+		values = append(values, entity.NumericValue(f.Name, raw*f.Scale+f.Offset))
+	}
+	return values, nil
+}
+
+func decodeField(typ string, data []byte, order binary.ByteOrder) (float64, error) {
+	switch typ {
+	case "int8":
+		return float64(int8(data[0])), nil
+	case "uint8":
+		return float64(data[0]), nil
+	case "int16":
+		return float64(int16(order.Uint16(data))), nil
+	case "uint16":
+		return float64(order.Uint16(data)), nil
+	case "int32":
+		return float64(int32(order.Uint32(data))), nil
+	case "uint32":
+		return float64(order.Uint32(data)), nil
+	case "int64":
+		return float64(int64(order.Uint64(data))), nil
+	case "uint64":
+		return float64(order.Uint64(data)), nil
+	case "float32":
+		return float64(math.Float32frombits(order.Uint32(data))), nil
+	case "float64":
+		return math.Float64frombits(order.Uint64(data)), nil
+	default:
+		return 0, fmt.Errorf("unsupported type %q", typ)
+	}
+}
@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserNMEA, func(_ config.ParserConfig, channels []entity.Channel) (Parser, error) {
+		return &NMEA{channels: channels}, nil
+	})
+}
+
+// Channels produced by the NMEA parser.
+const (
+	NMEAUTC        = "utc"   // text: hh:mm:ss.ss (GGA) or RFC 3339 date and time (RMC)
+	NMEALatitude   = "lat"   // decimal degrees, south is negative
+	NMEALongitude  = "lon"   // decimal degrees, west is negative
+	NMEAAltitude   = "alt"   // metres above mean sea level
+	NMEAFix        = "fix"   // GGA fix quality, 0 means no fix
+	NMEASatellites = "sats"  // satellites in use
+	NMEAValid      = "valid" // RMC status A
+	NMEASpeed      = "speed" // knots
+	NMEACourse     = "course"
+)
+
+// NMEA decodes GGA and RMC sentences of NMEA 0183 receivers of any talker
+// ($GPGGA, $GNRMC, ...). The checksum is required and verified. Other
+// sentences are stored without values. With a channel schema only the
+// declared channels are kept.
+type NMEA struct {
+	channels []entity.Channel
+}
+
+func (p *NMEA) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	fields, err := nmeaFields(strings.TrimSpace(string(frame)))
+	if err != nil {
+		return nil, err
+	}
+
+	var values []entity.ChannelValue
+	switch nmeaSentence(fields[0]) {
+	case "GGA":
+		values, err = parseGGA(fields)
+	case "RMC":
+		values, err = parseRMC(fields)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fields[0], err)
+	}
+	return p.declared(values), nil
+}
+
+func (p *NMEA) declared(values []entity.ChannelValue) []entity.ChannelValue {
+	if len(p.channels) == 0 {
+		return values
+	}
+	var kept []entity.ChannelValue
+	for _, ch := range p.channels {
+		for _, v := range values {
+			if v.Channel == ch.Name {
+				kept = append(kept, v)
+			}
+		}
+	}
+	return kept
+}
+
+// nmeaFields checks the framing and checksum of a sentence and splits it.
+// The first field is the address, e.g. "GPGGA" or a proprietary "PUBX".
+func nmeaFields(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("not an NMEA sentence")
+	}
+	star := strings.LastIndexByte(s, '*')
+	if star < 0 {
+		return nil, fmt.Errorf("missing checksum")
+	}
+	body, sum := s[1:star], s[star+1:]
+
+	want, err := strconv.ParseUint(sum, 16, 8)
+	if err != nil || len(sum) != 2 {
+		return nil, fmt.Errorf("invalid checksum %q", sum)
+	}
+	var got byte
+	for i := 0; i < len(body); i++ {
+		got ^= body[i]
+	}
+	if got != byte(want) {
+		return nil, fmt.Errorf("checksum mismatch: got %02X, sentence says %02X", got, want)
+	}
+
+	fields := strings.Split(body, ",")
+	if len(fields[0]) < 3 {
+		return nil, fmt.Errorf("invalid address %q", fields[0])
+	}
+	return fields, nil
+}
+
+// nmeaSentence returns the sentence type of a standard address, a talker
+// followed by three letters, or "" for a proprietary one ($P...).
+func nmeaSentence(address string) string {
+	if len(address) != 5 || address[0] == 'P' {
+		return ""
+	}
+	return address[2:]
+}
+
+// $GPGGA,hhmmss.ss,llll.ll,a,yyyyy.yy,a,q,nn,h.h,a.a,M,g.g,M,,*hh
+func parseGGA(f []string) ([]entity.ChannelValue, error) {
+	if len(f) < 10 {
+		return nil, fmt.Errorf("got %d fields, expected at least 10", len(f))
+	}
+	utc, err := nmeaTime(f[1])
+	if err != nil {
+		return nil, err
+	}
+	// Некоторые приёмники оставляют поле пустым, пока нет решения
+	var fix int
+	if f[6] != "" {
+		if fix, err = strconv.Atoi(f[6]); err != nil {
+			return nil, fmt.Errorf("invalid fix quality %q", f[6])
+		}
+	}
+	values := []entity.ChannelValue{entity.TextValue(NMEAUTC, utc)}
+	values = append(values, entity.NumericValue(NMEAFix, float64(fix)))
+	if f[7] != "" {
+		sats, err := strconv.Atoi(f[7])
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of satellites %q", f[7])
+		}
+		values = append(values, entity.NumericValue(NMEASatellites, float64(sats)))
+	}
+	if fix == 0 {
+		// Без решения координаты пустые или устаревшие
+		return values, nil
+	}
+
+	position, err := nmeaPosition(f[2], f[3], f[4], f[5])
+	if err != nil {
+		return nil, err
+	}
+	values = append(values, position...)
+	if f[9] != "" {
+		alt, err := strconv.ParseFloat(f[9], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid altitude %q", f[9])
+		}
+		values = append(values, entity.NumericValue(NMEAAltitude, alt))
+	}
+	return values, nil
+}
+
+// $GPRMC,hhmmss.ss,A,llll.ll,a,yyyyy.yy,a,x.x,x.x,ddmmyy,x.x,a*hh
+func parseRMC(f []string) ([]entity.ChannelValue, error) {
+	if len(f) < 10 {
+		return nil, fmt.Errorf("got %d fields, expected at least 10", len(f))
+	}
+	clock, err := nmeaTime(f[1])
+	if err != nil {
+		return nil, err
+	}
+	utc := clock
+	if f[9] != "" {
+		date, err := time.Parse("020106", f[9])
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", f[9])
+		}
+		utc = date.Format("2006-01-02") + "T" + clock + "Z"
+	}
+
+	valid := f[2] == "A"
+	values := []entity.ChannelValue{
+		entity.TextValue(NMEAUTC, utc),
+		entity.BoolValue(NMEAValid, valid),
+	}
+	if !valid {
+		return values, nil
+	}
+
+	position, err := nmeaPosition(f[3], f[4], f[5], f[6])
+	if err != nil {
+		return nil, err
+	}
+	values = append(values, position...)
+	for _, opt := range []struct{ name, field string }{{NMEASpeed, f[7]}, {NMEACourse, f[8]}} {
+		if opt.field == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(opt.field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", opt.name, opt.field)
+		}
+		values = append(values, entity.NumericValue(opt.name, v))
+	}
+	return values, nil
+}
+
+// nmeaTime converts hhmmss[.ss] to hh:mm:ss[.ss].
+func nmeaTime(s string) (string, error) {
+	if len(s) < 6 {
+		return "", fmt.Errorf("invalid time %q", s)
+	}
+	if _, err := time.Parse("150405", s[:6]); err != nil {
+		return "", fmt.Errorf("invalid time %q", s)
+	}
+	return s[0:2] + ":" + s[2:4] + ":" + s[4:], nil
+}
+
+func nmeaPosition(lat, ns, lon, ew string) ([]entity.ChannelValue, error) {
+	la, err := nmeaDegrees(lat, 2, ns, "N", "S")
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude: %w", err)
+	}
+	lo, err := nmeaDegrees(lon, 3, ew, "E", "W")
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude: %w", err)
+	}
+	return []entity.ChannelValue{
+		entity.NumericValue(NMEALatitude, la),
+		entity.NumericValue(NMEALongitude, lo),
+	}, nil
+}
+
+// nmeaDegrees converts (d)ddmm.mmmm and a hemisphere to decimal degrees.
+func nmeaDegrees(s string, degreeDigits int, hemisphere, positive, negative string) (float64, error) {
+	if len(s) < degreeDigits+2 {
+		return 0, fmt.Errorf("%q", s)
+	}
+	deg, err := strconv.Atoi(s[:degreeDigits])
+	if err != nil {
+		return 0, fmt.Errorf("%q", s)
+	}
+	min, err := strconv.ParseFloat(s[degreeDigits:], 64)
+	if err != nil || min >= 60 {
+		return 0, fmt.Errorf("%q", s)
+	}
+	v := float64(deg) + min/60
+	switch hemisphere {
+	case positive:
+		return v, nil
+	case negative:
+		return -v, nil
+	}
+	return 0, fmt.Errorf("hemisphere %q", hemisphere)
+}
+
+// GPSTime returns the time reported by a decoded GGA or RMC sentence if
+// the receiver had a fix. GGA carries no date; it is taken from ref, the
+// time the sentence was received, allowing for midnight in between.
+func GPSTime(values []entity.ChannelValue, ref time.Time) (time.Time, bool) {
+	var (
+		utc   string
+		fixed bool
+	)
+	for _, v := range values {
+		switch v.Channel {
+		case NMEAUTC:
+			utc = v.Text
+		case NMEAFix:
+			fixed = v.Value > 0
+		case NMEAValid:
+			fixed = v.Value != 0
+		}
+	}
+	if utc == "" || !fixed {
+		return time.Time{}, false
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, utc); err == nil {
+		return t, true
+	}
+	clock, err := time.Parse("15:04:05.999999999", utc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return withDate(clock, ref.UTC()), true
+}
+
+// withDate sets the time of day of clock on the date of ref, in the
+// location of ref, allowing for midnight in between.
+func withDate(clock, ref time.Time) time.Time {
+	t := time.Date(ref.Year(), ref.Month(), ref.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), ref.Location())
+	switch {
+	case t.Sub(ref) > 12*time.Hour:
+		t = t.AddDate(0, 0, -1)
+	case ref.Sub(t) > 12*time.Hour:
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
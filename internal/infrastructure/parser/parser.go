@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// Parser converts a frame into channel values.
+type Parser interface {
+	Parse(frame []byte) ([]entity.ChannelValue, error)
+}
+
+// Factory creates a parser. Channels are the channels declared by the
+// experiment and may be empty.
+type Factory func(cfg config.ParserConfig, channels []entity.Channel) (Parser, error)
+
+var registry = make(map[string]Factory)
+
+// Register makes a parser available under the given name.
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// Names returns the names of all registered parsers.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the parser selected by cfg. The "auto" parser decodes binary
+// records if a layout is configured, splits lines into the declared
+// channels if there are any and stores lines as they are otherwise.
+func New(cfg config.ParserConfig, channels []entity.Channel) (Parser, error) {
+	typ := cfg.Type
+	if typ == config.ParserAuto || typ == "" {
+		switch {
+		case len(cfg.Layout) > 0:
+			typ = config.ParserBinary
+		case len(channels) > 0:
+			typ = config.ParserDelimited
+		default:
+			typ = config.ParserRaw
+		}
+	}
+
+	factory, ok := registry[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown parser %q. Must be one of %s", typ, strings.Join(Names(), ", "))
+	}
+	return factory(cfg, channels)
+}
+
+func init() {
+	Register(config.ParserRaw, func(config.ParserConfig, []entity.Channel) (Parser, error) {
+		return rawParser{}, nil
+	})
+}
+
+// rawParser keeps frames as they are.
+type rawParser struct{}
+
+func (rawParser) Parse([]byte) ([]entity.ChannelValue, error) {
+	return nil, nil
+}
+
+// ConvertValue converts a text field to the type of the channel.
+func ConvertValue(ch entity.Channel, field string) (entity.ChannelValue, error) {
+	field = strings.TrimSpace(field)
+
+	switch ch.Type {
+	case entity.ChannelText:
+		return entity.TextValue(ch.Name, field), nil
+	case entity.ChannelBool:
+		b, err := parseBool(field)
+		if err != nil {
+			return entity.ChannelValue{}, fmt.Errorf("channel %q: %w", ch.Name, err)
+		}
+		return entity.BoolValue(ch.Name, b), nil
+	default:
+		f, err := parseNumber(field)
+		if err != nil {
+			return entity.ChannelValue{}, fmt.Errorf("channel %q: %w", ch.Name, err)
+		}
+		return entity.NumericValue(ch.Name, f), nil
+	}
+}
+
+// inferValue is used when no channel schema is declared: numbers become
+// numeric values, everything else text.
+func inferValue(name, field string) entity.ChannelValue {
+	field = strings.TrimSpace(field)
+	if f, err := strconv.ParseFloat(field, 64); err == nil {
+		return entity.NumericValue(name, f)
+	}
+	return entity.TextValue(name, field)
+}
+
+// namedValues converts name/value pairs. With a schema only the declared
+// channels are kept, in schema order; without one every pair is kept with
+// an inferred type.
+func namedValues(channels []entity.Channel, names, fields []string) ([]entity.ChannelValue, error) {
+	if len(channels) == 0 {
+		values := make([]entity.ChannelValue, len(names))
+		for i := range names {
+			values[i] = inferValue(names[i], fields[i])
+		}
+		return values, nil
+	}
+
+	byName := make(map[string]string, len(names))
+	for i, name := range names {
+		byName[name] = fields[i]
+	}
+
+	var values []entity.ChannelValue
+	for _, ch := range channels {
+		field, ok := byName[ch.Name]
+		if !ok {
+			continue
+		}
+		v, err := ConvertValue(ch, field)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no declared channel found in %s", strings.Join(names, ", "))
+	}
+	return values, nil
+}
+
+// positionalValues assigns fields to channels by position. Without a schema
+// the channels are called col1, col2, ...
+func positionalValues(channels []entity.Channel, fields []string) ([]entity.ChannelValue, error) {
+	if len(channels) == 0 {
+		values := make([]entity.ChannelValue, len(fields))
+		for i, field := range fields {
+			values[i] = inferValue(fmt.Sprintf("col%d", i+1), field)
+		}
+		return values, nil
+	}
+
+	if len(fields) != len(channels) {
+		return nil, fmt.Errorf("got %d fields, expected %d channels", len(fields), len(channels))
+	}
+
+	values := make([]entity.ChannelValue, len(fields))
+	for i, field := range fields {
+		v, err := ConvertValue(channels[i], field)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func parseNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return f, nil
+	}
+	// Many instruments use a decimal comma.
+	if f, err2 := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64); err2 == nil {
+		return f, nil
+	}
+	return 0, fmt.Errorf("%q is not a number", s)
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "t", "on", "yes", "y":
+		return true, nil
+	case "0", "false", "f", "off", "no", "n":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a boolean", s)
+}
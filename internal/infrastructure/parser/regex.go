@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+func init() {
+	Register(config.ParserRegex, func(cfg config.ParserConfig, channels []entity.Channel) (Parser, error) {
+		return NewRegex(cfg.Pattern, channels)
+	})
+}
+
+// Regex extracts channels from named groups, e.g.
+// `T=(?P<temp>[-\d.]+)C`.
+type Regex struct {
+	re       *regexp.Regexp
+	channels []entity.Channel
+}
+
+func NewRegex(pattern string, channels []entity.Channel) (*Regex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	named := 0
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			named++
+		}
+	}
+	if named == 0 {
+		return nil, fmt.Errorf("pattern %q has no named groups", pattern)
+	}
+
+	return &Regex{re: re, channels: channels}, nil
+}
+
+func (p *Regex) Parse(frame []byte) ([]entity.ChannelValue, error) {
+	match := p.re.FindSubmatch(frame)
+	if match == nil {
+		return nil, fmt.Errorf("line does not match pattern")
+	}
+
+	var names, fields []string
+	for i, name := range p.re.SubexpNames() {
+		if name == "" || match[i] == nil {
+			continue
+		}
+		names = append(names, name)
+		fields = append(fields, string(match[i]))
+	}
+	return namedValues(p.channels, names, fields)
+}
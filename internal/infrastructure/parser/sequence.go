@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+)
+
+// Sequence returns the record counter held by the named channel of the
+// decoded values. Text counters may be decimal or 0x-prefixed hex.
+func Sequence(channel string, values []entity.ChannelValue) (int64, error) {
+	for _, v := range values {
+		if v.Channel != channel {
+			continue
+		}
+		if v.Type == entity.ChannelText {
+			return strconv.ParseInt(strings.TrimSpace(v.Text), 0, 64)
+		}
+		if v.Value != math.Trunc(v.Value) {
+			return 0, fmt.Errorf("%v is not an integer", v.Value)
+		}
+		return int64(v.Value), nil
+	}
+	return 0, fmt.Errorf("no channel %q", channel)
+}
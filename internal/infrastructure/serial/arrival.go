@@ -0,0 +1,72 @@
+package serial
+
+import (
+	"bufio"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Frame is a frame read from the port. ReceivedAt is the host time the
+// first byte of the frame was read, before any queueing on the way to the
+// consumer.
+type Frame struct {
+	Data       []byte
+	ReceivedAt time.Time
+}
+
+// arrivalReader remembers when the bytes read from the port arrived, so
+// that a frame can be stamped with the arrival time of its first byte.
+type arrivalReader struct {
+	r      io.Reader
+	now    func() time.Time // time.Now unless the bytes carry their own time
+	total  *atomic.Uint64   // bytes read over all connections of the listener
+	read   int64            // bytes read so far
+	chunks []arrival        // chunks not yet consumed by the framer
+}
+
+type arrival struct {
+	end int64 // position after the last byte of the chunk
+	at  time.Time
+}
+
+func (a *arrivalReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.read += int64(n)
+		a.total.Add(uint64(n))
+		a.chunks = append(a.chunks, arrival{end: a.read, at: a.now()})
+	}
+	return n, err
+}
+
+// consumed returns the position of the next byte the framer will get from
+// the buffered reader on top of a.
+func (a *arrivalReader) consumed(br *bufio.Reader) int64 {
+	return a.read - int64(br.Buffered())
+}
+
+// at returns the arrival time of the byte at pos and forgets the chunks
+// before it.
+func (a *arrivalReader) at(pos int64) time.Time {
+	for len(a.chunks) > 1 && a.chunks[0].end <= pos {
+		a.chunks = a.chunks[1:]
+	}
+	if len(a.chunks) == 0 {
+		return time.Now()
+	}
+	return a.chunks[0].at
+}
+
+// frameStart estimates the position of the first byte of a frame from the
+// positions before and after reading it. Framers skip separators before a
+// frame and consume at least one terminator byte or none, and escaped
+// encodings are longer than the frame, so end-len(frame)-1 lies within the
+// frame unless the frame has no terminator.
+func frameStart(start, end int64, frame []byte) int64 {
+	pos := end - int64(len(frame)) - 1
+	if pos < start {
+		pos = start
+	}
+	return pos
+}
@@ -0,0 +1,42 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"go.bug.st/serial"
+)
+
+// capturePort copies the bytes read from the port to capture writers. A
+// failing writer is logged once and does not affect reading or the other
+// writers.
+type capturePort struct {
+	serial.Port
+	tees []*tee
+	name string
+}
+
+type tee struct {
+	w      io.Writer
+	failed bool
+}
+
+func (cp *capturePort) Read(p []byte) (int, error) {
+	n, err := cp.Port.Read(p)
+	if n > 0 {
+		for _, t := range cp.tees {
+			if t.failed {
+				continue
+			}
+			if _, werr := t.w.Write(p[:n]); werr != nil {
+				t.failed = true
+				if !errors.Is(werr, os.ErrClosed) {
+					log.Printf("Port %s: capture stopped: %v", cp.name, werr)
+				}
+			}
+		}
+	}
+	return n, err
+}
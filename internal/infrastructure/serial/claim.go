@@ -0,0 +1,33 @@
+package serial
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Devices currently held open by a PortListener. A device can only be
+// owned by one listener at a time.
+var (
+	claimsMu sync.Mutex
+	claims   = make(map[string]*PortListener)
+)
+
+func claimDevice(device string, owner *PortListener) error {
+	claimsMu.Lock()
+	defer claimsMu.Unlock()
+
+	if current, ok := claims[device]; ok && current != owner {
+		return fmt.Errorf("device %s is already opened by port %q", device, current.cfg.Name)
+	}
+	claims[device] = owner
+	return nil
+}
+
+func releaseDevice(device string, owner *PortListener) {
+	claimsMu.Lock()
+	defer claimsMu.Unlock()
+
+	if claims[device] == owner {
+		delete(claims, device)
+	}
+}
@@ -0,0 +1,112 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+	"go.bug.st/serial/enumerator"
+)
+
+// PortInfo describes a serial port found on the system.
+type PortInfo struct {
+	Name         string `json:"name"`
+	IsUSB        bool   `json:"is_usb"`
+	VID          string `json:"vid,omitempty"`
+	PID          string `json:"pid,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	Product      string `json:"product,omitempty"`
+}
+
+// ListPorts returns the serial ports currently present, sorted by name.
+func ListPorts() ([]PortInfo, error) {
+	details, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]PortInfo, 0, len(details))
+	for _, d := range details {
+		ports = append(ports, PortInfo{
+			Name:         d.Name,
+			IsUSB:        d.IsUSB,
+			VID:          d.VID,
+			PID:          d.PID,
+			SerialNumber: d.SerialNumber,
+			Product:      d.Product,
+		})
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Name < ports[j].Name })
+	return ports, nil
+}
+
+// Matches reports whether the port is the USB device described by m.
+// Empty fields of m match anything.
+func (p PortInfo) Matches(m config.DeviceMatch) bool {
+	if m.IsEmpty() || !p.IsUSB {
+		return false
+	}
+	return matchField(m.VID, p.VID) && matchField(m.PID, p.PID) && matchField(m.SerialNumber, p.SerialNumber)
+}
+
+func matchField(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// resolveDevice returns the current path of the device described by m.
+func resolveDevice(m config.DeviceMatch) (string, error) {
+	ports, err := ListPorts()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range ports {
+		if p.Matches(m) {
+			return p.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no device matching %s is connected", m)
+}
+
+// WatchPorts polls the list of serial ports and calls onAdded with the
+// ports that appeared since the previous poll, until ctx is cancelled.
+func WatchPorts(ctx context.Context, interval time.Duration, onAdded func([]PortInfo)) {
+	known := make(map[string]PortInfo)
+	if ports, err := ListPorts(); err == nil {
+		for _, p := range ports {
+			known[p.Name] = p
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ports, err := ListPorts()
+		if err != nil {
+			continue
+		}
+
+		current := make(map[string]PortInfo, len(ports))
+		var added []PortInfo
+		for _, p := range ports {
+			current[p.Name] = p
+			if old, ok := known[p.Name]; !ok || old != p {
+				added = append(added, p)
+			}
+		}
+		known = current
+
+		if len(added) > 0 {
+			onAdded(added)
+		}
+	}
+}
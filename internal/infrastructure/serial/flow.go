@@ -0,0 +1,157 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+	"go.bug.st/serial"
+)
+
+const (
+	xon  = 0x11
+	xoff = 0x13
+)
+
+// flowControl implements RTS/CTS and XON/XOFF handshaking in software:
+// go.bug.st/serial always opens the port with hardware flow control
+// disabled, so the modem lines and control characters are driven here.
+type flowControl struct {
+	port serial.Port
+	mode string
+
+	mu           sync.Mutex
+	paused       bool // we asked the remote side to stop sending
+	remotePaused bool // the remote side sent XOFF
+}
+
+func newFlowControl(port serial.Port, mode string) *flowControl {
+	return &flowControl{port: port, mode: mode}
+}
+
+// reader returns the stream data should be read from. In XON/XOFF mode the
+// control characters are removed from the data and tracked instead.
+func (fc *flowControl) reader() io.Reader {
+	if fc.mode != config.FlowXONXOFF {
+		return fc.port
+	}
+	return &xonxoffReader{r: fc.port, fc: fc}
+}
+
+func (fc *flowControl) pause() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.paused {
+		return
+	}
+
+	var err error
+	switch fc.mode {
+	case config.FlowRTSCTS:
+		err = fc.port.SetRTS(false)
+	case config.FlowXONXOFF:
+		_, err = fc.port.Write([]byte{xoff})
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to pause remote transmitter: %v", err)
+		return
+	}
+	fc.paused = true
+}
+
+func (fc *flowControl) resume() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if !fc.paused {
+		return
+	}
+
+	var err error
+	switch fc.mode {
+	case config.FlowRTSCTS:
+		err = fc.port.SetRTS(true)
+	case config.FlowXONXOFF:
+		_, err = fc.port.Write([]byte{xon})
+	}
+	if err != nil {
+		log.Printf("Failed to resume remote transmitter: %v", err)
+		return
+	}
+	fc.paused = false
+}
+
+// clearToSend reports whether the remote side is ready to receive: CTS is
+// asserted in RTS/CTS mode, no XOFF is pending in XON/XOFF mode.
+func (fc *flowControl) clearToSend() (bool, error) {
+	switch fc.mode {
+	case config.FlowRTSCTS:
+		bits, err := fc.port.GetModemStatusBits()
+		if err != nil {
+			return false, err
+		}
+		return bits.CTS, nil
+	case config.FlowXONXOFF:
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		return !fc.remotePaused, nil
+	default:
+		return true, nil
+	}
+}
+
+// waitClearToSend blocks until the remote side is ready to receive or ctx
+// is done.
+func (fc *flowControl) waitClearToSend(ctx context.Context) error {
+	for {
+		ok, err := fc.clearToSend()
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("remote side is not ready to receive: %w", ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (fc *flowControl) setRemotePaused(paused bool) {
+	fc.mu.Lock()
+	fc.remotePaused = paused
+	fc.mu.Unlock()
+}
+
+type xonxoffReader struct {
+	r  io.Reader
+	fc *flowControl
+}
+
+func (xr *xonxoffReader) Read(p []byte) (int, error) {
+	for {
+		n, err := xr.r.Read(p)
+		out := 0
+		for _, b := range p[:n] {
+			switch b {
+			case xon:
+				xr.fc.setRemotePaused(false)
+			case xoff:
+				xr.fc.setRemotePaused(true)
+			default:
+				p[out] = b
+				out++
+			}
+		}
+		// Do not report an empty read when only control characters arrived.
+		if out > 0 || err != nil || n == 0 {
+			return out, err
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package serial
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// Framer splits the byte stream read from a port into frames.
+type Framer interface {
+	ReadFrame(r *bufio.Reader) ([]byte, error)
+}
+
+// FrameError reports a malformed frame. The framer has already
+// resynchronized when it is returned, so reading may continue.
+type FrameError struct {
+	Reason string
+}
+
+func (e *FrameError) Error() string {
+	return "frame error: " + e.Reason
+}
+
+func NewFramer(cfg config.FramingConfig) (Framer, error) {
+	switch cfg.Type {
+	case config.FramingLine, "":
+		return &LineFramer{MaxLength: cfg.MaxLength}, nil
+	case config.FramingDelimiter:
+		return &DelimiterFramer{Delimiter: []byte(cfg.Delimiter), MaxLength: cfg.MaxLength}, nil
+	case config.FramingFixed:
+		return &FixedLengthFramer{Length: cfg.Length}, nil
+	case config.FramingSTXETX:
+		return &STXETXFramer{MaxLength: cfg.MaxLength}, nil
+	case config.FramingSLIP:
+		return &SLIPFramer{MaxLength: cfg.MaxLength}, nil
+	case config.FramingCOBS:
+		return &COBSFramer{MaxLength: cfg.MaxLength}, nil
+	case config.FramingModbus:
+		return &ModbusFramer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown framing %q", cfg.Type)
+	}
+}
+
+// LineFramer splits text on CR, LF or CRLF. Empty lines are skipped.
+type LineFramer struct {
+	MaxLength int
+}
+
+func (f *LineFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	var frame []byte
+	overrun := false
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == '\r' || b == '\n' {
+			if overrun {
+				return nil, &FrameError{Reason: fmt.Sprintf("line longer than %d bytes", f.MaxLength)}
+			}
+			if len(frame) == 0 {
+				continue
+			}
+			return frame, nil
+		}
+		if overrun {
+			continue
+		}
+		if f.MaxLength > 0 && len(frame) >= f.MaxLength {
+			overrun = true
+			frame = nil
+			continue
+		}
+		frame = append(frame, b)
+	}
+}
+
+// DelimiterFramer ends a frame at an arbitrary byte sequence, which is
+// not included in the frame.
+type DelimiterFramer struct {
+	Delimiter []byte
+	MaxLength int
+}
+
+func (f *DelimiterFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	last := f.Delimiter[len(f.Delimiter)-1]
+	var frame []byte
+	overrun := false
+	for {
+		chunk, err := r.ReadSlice(last)
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		frame = append(frame, chunk...)
+		if err == nil && bytes.HasSuffix(frame, f.Delimiter) {
+			if overrun || (f.MaxLength > 0 && len(frame)-len(f.Delimiter) > f.MaxLength) {
+				return nil, &FrameError{Reason: fmt.Sprintf("frame longer than %d bytes", f.MaxLength)}
+			}
+			return frame[:len(frame)-len(f.Delimiter)], nil
+		}
+		if f.MaxLength > 0 && len(frame) > f.MaxLength+len(f.Delimiter) {
+			// Keep only enough bytes to recognize a delimiter split
+			// across reads.
+			overrun = true
+			frame = append(frame[:0], frame[len(frame)-len(f.Delimiter)+1:]...)
+		}
+	}
+}
+
+// FixedLengthFramer reads records of a constant size.
+type FixedLengthFramer struct {
+	Length int
+}
+
+func (f *FixedLengthFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, f.Length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+const (
+	stx = 0x02
+	etx = 0x03
+)
+
+// STXETXFramer returns the bytes between STX and ETX. Bytes outside of a
+// frame are ignored.
+type STXETXFramer struct {
+	MaxLength int
+}
+
+func (f *STXETXFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == stx {
+			break
+		}
+	}
+
+	var frame []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case etx:
+			return frame, nil
+		case stx:
+			// The previous frame was cut off; this STX starts a new one.
+			r.UnreadByte()
+			return nil, &FrameError{Reason: "STX inside frame"}
+		}
+		if f.MaxLength > 0 && len(frame) >= f.MaxLength {
+			return nil, &FrameError{Reason: fmt.Sprintf("frame longer than %d bytes", f.MaxLength)}
+		}
+		frame = append(frame, b)
+	}
+}
+
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+// SLIPFramer decodes RFC 1055 frames.
+type SLIPFramer struct {
+	MaxLength int
+}
+
+func (f *SLIPFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	var frame []byte
+	var frameErr *FrameError
+	escaped := false
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if b == slipEnd {
+			if frameErr != nil {
+				return nil, frameErr
+			}
+			if escaped {
+				return nil, &FrameError{Reason: "SLIP escape at end of frame"}
+			}
+			if len(frame) == 0 {
+				continue
+			}
+			return frame, nil
+		}
+		if frameErr != nil {
+			continue
+		}
+
+		if escaped {
+			escaped = false
+			switch b {
+			case slipEscEnd:
+				b = slipEnd
+			case slipEscEsc:
+				b = slipEsc
+			default:
+				frameErr = &FrameError{Reason: fmt.Sprintf("invalid SLIP escape 0x%02X", b)}
+				continue
+			}
+		} else if b == slipEsc {
+			escaped = true
+			continue
+		}
+
+		if f.MaxLength > 0 && len(frame) >= f.MaxLength {
+			frameErr = &FrameError{Reason: fmt.Sprintf("frame longer than %d bytes", f.MaxLength)}
+			continue
+		}
+		frame = append(frame, b)
+	}
+}
+
+// COBSFramer decodes Consistent Overhead Byte Stuffing frames terminated
+// by a zero byte.
+type COBSFramer struct {
+	MaxLength int
+}
+
+func (f *COBSFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	// Кодирование добавляет байт на каждые 254 байта данных и ещё один
+	limit := 0
+	if f.MaxLength > 0 {
+		limit = f.MaxLength + f.MaxLength/254 + 1
+	}
+	var encoded []byte
+	overrun := false
+	for {
+		chunk, err := r.ReadSlice(0x00)
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		if !overrun {
+			encoded = append(encoded, chunk...)
+		}
+		if err == nil {
+			if overrun {
+				return nil, &FrameError{Reason: fmt.Sprintf("frame longer than %d bytes", f.MaxLength)}
+			}
+			encoded = encoded[:len(encoded)-1]
+			if len(encoded) == 0 {
+				continue
+			}
+			if limit > 0 && len(encoded) > limit {
+				return nil, &FrameError{Reason: fmt.Sprintf("frame longer than %d bytes", f.MaxLength)}
+			}
+			return decodeCOBS(encoded)
+		}
+		if limit > 0 && len(encoded) > limit {
+			// The rest of the frame is skipped up to its terminator.
+			overrun = true
+			encoded = nil
+		}
+	}
+}
+
+func decodeCOBS(encoded []byte) ([]byte, error) {
+	frame := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded); {
+		code := int(encoded[i])
+		if i+code > len(encoded) {
+			return nil, &FrameError{Reason: "COBS block runs past end of frame"}
+		}
+		frame = append(frame, encoded[i+1:i+code]...)
+		i += code
+		if code < 0xFF && i < len(encoded) {
+			frame = append(frame, 0x00)
+		}
+	}
+	return frame, nil
+}
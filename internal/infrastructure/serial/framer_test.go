@@ -0,0 +1,171 @@
+package serial
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// frameResult is a frame, or the kind of error returned instead.
+type frameResult struct {
+	frame    string
+	frameErr bool // a FrameError
+}
+
+// readFrames reads frames until EOF. A small buffer makes frames span
+// several reads of the buffered reader.
+func readFrames(t *testing.T, f Framer, input []byte) []frameResult {
+	t.Helper()
+	r := bufio.NewReaderSize(bytes.NewReader(input), 16)
+	var got []frameResult
+	for i := 0; i < 100; i++ {
+		frame, err := f.ReadFrame(r)
+		var frameErr *FrameError
+		switch {
+		case err == nil:
+			got = append(got, frameResult{frame: string(frame)})
+		case errors.As(err, &frameErr):
+			got = append(got, frameResult{frameErr: true})
+		case err == io.EOF:
+			return got
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	t.Fatal("framer does not stop at EOF")
+	return nil
+}
+
+func TestFramers(t *testing.T) {
+	long := bytes.Repeat([]byte{'x'}, 40)
+	tests := []struct {
+		name   string
+		cfg    config.FramingConfig
+		input  []byte
+		frames []frameResult
+	}{
+		{
+			name:   "line endings",
+			cfg:    config.FramingConfig{Type: config.FramingLine},
+			input:  []byte("a\rb\nc\r\n\r\nd"),
+			frames: []frameResult{{frame: "a"}, {frame: "b"}, {frame: "c"}},
+		},
+		{
+			name:   "line overrun",
+			cfg:    config.FramingConfig{Type: config.FramingLine, MaxLength: 8},
+			input:  append(append([]byte("ok\n"), long...), "\nnext\n"...),
+			frames: []frameResult{{frame: "ok"}, {frameErr: true}, {frame: "next"}},
+		},
+		{
+			name:   "delimiter",
+			cfg:    config.FramingConfig{Type: config.FramingDelimiter, Delimiter: "##"},
+			input:  []byte("a#b##c##"),
+			frames: []frameResult{{frame: "a#b"}, {frame: "c"}},
+		},
+		{
+			name:   "delimiter overrun",
+			cfg:    config.FramingConfig{Type: config.FramingDelimiter, Delimiter: "##", MaxLength: 8},
+			input:  append(append([]byte("ok##"), long...), "##next##"...),
+			frames: []frameResult{{frame: "ok"}, {frameErr: true}, {frame: "next"}},
+		},
+		{
+			name:   "fixed length drops a partial record",
+			cfg:    config.FramingConfig{Type: config.FramingFixed, Length: 3},
+			input:  []byte("abcdefgh"),
+			frames: []frameResult{{frame: "abc"}, {frame: "def"}},
+		},
+		{
+			name:   "STX/ETX",
+			cfg:    config.FramingConfig{Type: config.FramingSTXETX},
+			input:  []byte("junk\x02one\x03\x02two\x03"),
+			frames: []frameResult{{frame: "one"}, {frame: "two"}},
+		},
+		{
+			name:   "STX inside a frame",
+			cfg:    config.FramingConfig{Type: config.FramingSTXETX},
+			input:  []byte("\x02cut\x02whole\x03"),
+			frames: []frameResult{{frameErr: true}, {frame: "whole"}},
+		},
+		{
+			name:   "STX/ETX overrun",
+			cfg:    config.FramingConfig{Type: config.FramingSTXETX, MaxLength: 8},
+			input:  append(append([]byte{stx}, long...), "\x03\x02next\x03"...),
+			frames: []frameResult{{frameErr: true}, {frame: "next"}},
+		},
+		{
+			name:   "SLIP with escapes",
+			cfg:    config.FramingConfig{Type: config.FramingSLIP},
+			input:  []byte{slipEnd, 'a', slipEsc, slipEscEnd, slipEsc, slipEscEsc, 'b', slipEnd, slipEnd, 'c', slipEnd},
+			frames: []frameResult{{frame: "a\xc0\xdbb"}, {frame: "c"}},
+		},
+		{
+			name:   "SLIP invalid escape",
+			cfg:    config.FramingConfig{Type: config.FramingSLIP},
+			input:  []byte{'a', slipEsc, 'x', 'b', slipEnd, 'c', slipEnd},
+			frames: []frameResult{{frameErr: true}, {frame: "c"}},
+		},
+		{
+			name:   "SLIP overrun",
+			cfg:    config.FramingConfig{Type: config.FramingSLIP, MaxLength: 8},
+			input:  append(append([]byte{}, long...), slipEnd, 'c', slipEnd),
+			frames: []frameResult{{frameErr: true}, {frame: "c"}},
+		},
+		{
+			name: "COBS",
+			cfg:  config.FramingConfig{Type: config.FramingCOBS},
+			// 11 22 00 33 and a single zero
+			input:  []byte{0x03, 0x11, 0x22, 0x02, 0x33, 0x00, 0x00, 0x01, 0x01, 0x00},
+			frames: []frameResult{{frame: "\x11\x22\x00\x33"}, {frame: "\x00"}},
+		},
+		{
+			name:   "COBS block past the end",
+			cfg:    config.FramingConfig{Type: config.FramingCOBS},
+			input:  []byte{0x05, 0x11, 0x00, 0x02, 0x33, 0x00},
+			frames: []frameResult{{frameErr: true}, {frame: "\x33"}},
+		},
+		{
+			name:   "COBS overrun",
+			cfg:    config.FramingConfig{Type: config.FramingCOBS, MaxLength: 8},
+			input:  append(append([]byte{}, long...), 0x00, 0x02, 0x33, 0x00),
+			frames: []frameResult{{frameErr: true}, {frame: "\x33"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFramer(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readFrames(t, f, tt.input); !reflect.DeepEqual(got, tt.frames) {
+				t.Errorf("got %+v, want %+v", got, tt.frames)
+			}
+		})
+	}
+}
+
+func TestDecodeCOBSLongBlock(t *testing.T) {
+	// 254 non-zero bytes fill a block of code 0xFF, which adds no zero
+	data := bytes.Repeat([]byte{0x42}, 300)
+	encoded := append([]byte{0xFF}, data[:254]...)
+	encoded = append(encoded, byte(len(data)-254+1))
+	encoded = append(encoded, data[254:]...)
+	got, err := decodeCOBS(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decoded %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestNewFramerUnknown(t *testing.T) {
+	if _, err := NewFramer(config.FramingConfig{Type: "morse"}); err == nil {
+		t.Fatal("unknown framing accepted")
+	}
+}
@@ -0,0 +1,141 @@
+package serial
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/modbus"
+)
+
+// ModbusFramer reads Modbus RTU response frames. The length is taken from
+// the frame header; a frame with a bad CRC is dropped together with
+// everything buffered after it, since the frame boundary is lost.
+type ModbusFramer struct{}
+
+func (f *ModbusFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, 0, 8)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		frame = append(frame, b)
+		length, ok := modbus.ResponseLength(frame)
+		if !ok {
+			continue
+		}
+
+		rest := make([]byte, length-len(frame))
+		if _, err := io.ReadFull(r, rest); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		frame = append(frame, rest...)
+		if !modbus.CheckCRC(frame) {
+			r.Discard(r.Buffered())
+			return nil, &FrameError{Reason: "Modbus CRC mismatch"}
+		}
+		return frame, nil
+	}
+}
+
+// PollModbus acquires data as a Modbus RTU master: every interval all
+// register blocks of the port's register map are read and the values are
+// passed to dataChan as one record (see modbus.Plan). If a slave does not
+// answer or answers with an error, the cycle is abandoned and reported to
+// timeoutChan. Errors end polling as in Listen.
+func (pl *PortListener) PollModbus(ctx context.Context, dataChan chan<- Frame, timeoutChan chan<- PollTimeout, errorChan chan<- error) {
+	frames := make(chan Frame)
+	go pl.Listen(ctx, frames, errorChan)
+
+	plan := modbus.NewPlan(pl.cfg.Modbus.Registers)
+	ticker := time.NewTicker(time.Duration(pl.cfg.Modbus.Interval))
+	defer ticker.Stop()
+
+	for {
+		if pl.State() == StateConnected {
+			if !pl.modbusCycle(ctx, plan, frames, dataChan, timeoutChan) {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case frame := <-frames:
+			log.Printf("Port %s: dropped unsolicited Modbus frame % X", pl.cfg.Name, frame.Data)
+		case <-ticker.C:
+		}
+	}
+}
+
+// modbusCycle reads every block once. It returns false when ctx is done.
+func (pl *PortListener) modbusCycle(ctx context.Context, plan *modbus.Plan, frames <-chan Frame, dataChan chan<- Frame, timeoutChan chan<- PollTimeout) bool {
+	gap := modbus.FrameGap(pl.cfg.BaudRate)
+	timeout := time.Duration(pl.cfg.Modbus.Timeout)
+
+	data := make([][]byte, len(plan.Blocks))
+	var receivedAt time.Time // the record is stamped with its first response
+	for i, b := range plan.Blocks {
+		// Тишина между кадрами не короче 3,5 символов
+		select {
+		case <-ctx.Done():
+			return false
+		case frame := <-frames:
+			log.Printf("Port %s: dropped unsolicited Modbus frame % X", pl.cfg.Name, frame.Data)
+		case <-time.After(gap):
+		}
+
+		sentAt := time.Now()
+		regs, at, err := pl.modbusRead(ctx, b, timeout, frames)
+		if ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			pl.pollTimeouts.Add(1)
+			select {
+			case timeoutChan <- PollTimeout{Query: b.String(), SentAt: sentAt, Until: time.Now(), Reason: err.Error()}:
+			case <-ctx.Done():
+				return false
+			}
+			return true
+		}
+		data[i] = regs
+		if i == 0 {
+			receivedAt = at
+		}
+	}
+
+	select {
+	case dataChan <- Frame{Data: plan.Record(data), ReceivedAt: receivedAt}:
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+func (pl *PortListener) modbusRead(ctx context.Context, b modbus.Block, timeout time.Duration, frames <-chan Frame) ([]byte, time.Time, error) {
+	writeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := pl.Write(writeCtx, modbus.ReadRequest(b.Slave, b.Function, b.Address, b.Count)); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case frame := <-frames:
+		regs, err := modbus.ParseReadResponse(frame.Data, b.Slave, b.Function, b.Count)
+		return regs, frame.ReceivedAt, err
+	case <-timer.C:
+		return nil, time.Time{}, fmt.Errorf("no response within %s", timeout)
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+}
@@ -0,0 +1,52 @@
+package serial
+
+import (
+	"fmt"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+	"go.bug.st/serial"
+)
+
+func modeFromConfig(cfg config.PortConfig) (*serial.Mode, error) {
+	mode := &serial.Mode{
+		BaudRate: cfg.BaudRate,
+		DataBits: cfg.DataBits,
+	}
+
+	// Both lines are raised by default. Setting them explicitly needs modem
+	// control ioctls, which pseudo-terminals and some adapters reject.
+	if !cfg.DTR || !cfg.RTS {
+		mode.InitialStatusBits = &serial.ModemOutputBits{
+			DTR: cfg.DTR,
+			RTS: cfg.RTS,
+		}
+	}
+
+	switch cfg.Parity {
+	case config.ParityNone, "":
+		mode.Parity = serial.NoParity
+	case config.ParityOdd:
+		mode.Parity = serial.OddParity
+	case config.ParityEven:
+		mode.Parity = serial.EvenParity
+	case config.ParityMark:
+		mode.Parity = serial.MarkParity
+	case config.ParitySpace:
+		mode.Parity = serial.SpaceParity
+	default:
+		return nil, fmt.Errorf("unsupported parity %q", cfg.Parity)
+	}
+
+	switch cfg.StopBits {
+	case "1", "":
+		mode.StopBits = serial.OneStopBit
+	case "1.5":
+		mode.StopBits = serial.OnePointFiveStopBits
+	case "2":
+		mode.StopBits = serial.TwoStopBits
+	default:
+		return nil, fmt.Errorf("unsupported stop bits %q", cfg.StopBits)
+	}
+
+	return mode, nil
+}
@@ -0,0 +1,68 @@
+package serial
+
+import (
+	"net"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+	"go.bug.st/serial"
+)
+
+const (
+	dialTimeout   = 10 * time.Second
+	tcpKeepAlive  = 30 * time.Second
+	negotiateWait = 2 * time.Second // how long to wait for the RFC 2217 server to confirm the settings
+)
+
+func init() {
+	newPortListener := func(cfg config.PortConfig) (DataSource, error) {
+		return NewPortListener(cfg), nil
+	}
+	RegisterSource(config.SourceTCP, newPortListener)
+	RegisterSource(config.SourceRFC2217, newPortListener)
+}
+
+// openNetwork connects to a serial-to-Ethernet converter. A raw TCP
+// converter is set up on its own side; an RFC 2217 server is told the line
+// settings of mode.
+func openNetwork(device string, mode *serial.Mode) (serial.Port, error) {
+	source, addr, _ := config.NetworkAddress(device)
+	d := net.Dialer{Timeout: dialTimeout, KeepAlive: tcpKeepAlive}
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if source == config.SourceTCP {
+		return &tcpPort{conn: conn}, nil
+	}
+
+	p := newRFC2217Port(conn, device)
+	if err := p.negotiate(mode); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// tcpPort is a raw TCP connection to a converter. The line settings and
+// modem lines are out of reach: the converter is configured on its own
+// web page, and the lines are reported as up.
+type tcpPort struct {
+	conn net.Conn
+}
+
+func (tp *tcpPort) Read(p []byte) (int, error)  { return tp.conn.Read(p) }
+func (tp *tcpPort) Write(p []byte) (int, error) { return tp.conn.Write(p) }
+func (tp *tcpPort) Close() error                { return tp.conn.Close() }
+
+func (tp *tcpPort) SetMode(mode *serial.Mode) error      { return nil }
+func (tp *tcpPort) Drain() error                         { return nil }
+func (tp *tcpPort) ResetInputBuffer() error              { return nil }
+func (tp *tcpPort) ResetOutputBuffer() error             { return nil }
+func (tp *tcpPort) SetDTR(dtr bool) error                { return nil }
+func (tp *tcpPort) SetRTS(rts bool) error                { return nil }
+func (tp *tcpPort) SetReadTimeout(t time.Duration) error { return nil }
+func (tp *tcpPort) Break(d time.Duration) error          { return nil }
+func (tp *tcpPort) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{CTS: true, DSR: true, DCD: true}, nil
+}
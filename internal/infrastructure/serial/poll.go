@@ -0,0 +1,96 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PollTimeout reports a query the device did not answer in time.
+type PollTimeout struct {
+	Query  string
+	SentAt time.Time
+	Until  time.Time
+	Reason string
+}
+
+// Poll acquires data by query and response: every interval of the poll
+// settings the query is sent and the first frame received within the
+// timeout is passed to dataChan. Frames that arrive outside of a query are
+// dropped, so a late answer is not taken for the response to the next
+// query. Unanswered queries go to timeoutChan, errors end polling as in
+// Listen.
+func (pl *PortListener) Poll(ctx context.Context, dataChan chan<- Frame, timeoutChan chan<- PollTimeout, errorChan chan<- error) {
+	frames := make(chan Frame)
+	go pl.Listen(ctx, frames, errorChan)
+
+	poll := pl.cfg.Poll
+	query := []byte(poll.Query + pl.cfg.CommandEnding)
+	ticker := time.NewTicker(time.Duration(poll.Interval))
+	defer ticker.Stop()
+
+	for {
+		if pl.State() == StateConnected {
+			if !pl.query(ctx, query, frames, dataChan, timeoutChan) {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case frame := <-frames:
+			log.Printf("Port %s: dropped unsolicited frame %q", pl.cfg.Name, frame.Data)
+		case <-ticker.C:
+		}
+	}
+}
+
+// query sends one query and waits for its response. It returns false when
+// ctx is done.
+func (pl *PortListener) query(ctx context.Context, query []byte, frames <-chan Frame, dataChan chan<- Frame, timeoutChan chan<- PollTimeout) bool {
+	timeout := time.Duration(pl.cfg.Poll.Timeout)
+	writeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sentAt := time.Now()
+	if err := pl.Write(writeCtx, query); err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("Port %s: failed to send query: %v", pl.cfg.Name, err)
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case frame := <-frames:
+		select {
+		case dataChan <- frame:
+		case <-ctx.Done():
+			return false
+		}
+	case <-timer.C:
+		pl.pollTimeouts.Add(1)
+		select {
+		case timeoutChan <- PollTimeout{
+			Query:  pl.cfg.Poll.Query,
+			SentAt: sentAt,
+			Until:  time.Now(),
+			Reason: fmt.Sprintf("no response within %s", timeout),
+		}:
+		case <-ctx.Done():
+			return false
+		}
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+// PollTimeouts returns the number of queries left without a response.
+func (pl *PortListener) PollTimeouts() uint64 {
+	return pl.pollTimeouts.Load()
+}
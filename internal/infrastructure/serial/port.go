@@ -3,102 +3,468 @@ package serial
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
 	"go.bug.st/serial"
 )
 
 type PortListener struct {
-	port     serial.Port
-	baudRate int
-	portName string
+	cfg      config.PortConfig
 	reader   *bufio.Reader
+	arrivals *arrivalReader // under reader, to stamp frames
+	flow     *flowControl
+	framer   Framer
+	wake     chan struct{}
+	capture  []io.Writer // get a copy of every byte read
+
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	port   serial.Port
+	device string // path the port was last opened at
+	closed bool
+
+	stateMu       sync.Mutex
+	state         State
+	stateSince    time.Time
+	lastErr       error
+	attempts      int // failed attempts since the port was lost
+	onStateChange func(StateChange)
+
+	bytesRead    atomic.Uint64
+	frameErrors  atomic.Uint64
+	pollTimeouts atomic.Uint64
+	connects     atomic.Uint64 // successful connects, to redo device setup after a reconnect
 }
 
-func NewPortListener(portName string, baudRate int) *PortListener {
+func NewPortListener(cfg config.PortConfig) *PortListener {
 	return &PortListener{
-		portName: portName,
-		baudRate: baudRate,
+		cfg:        cfg,
+		wake:       make(chan struct{}, 1),
+		state:      StateStopped,
+		stateSince: time.Now(),
 	}
 }
 
+// OnStateChange sets a function called on every state change. It must be
+// set before Listen. It may be called from Listen and from Close.
+func (pl *PortListener) OnStateChange(fn func(StateChange)) {
+	pl.onStateChange = fn
+}
+
+// Capture tees every byte read from the port, including flow control
+// characters, into w. It must be called before the port is opened, and may
+// be called for several writers.
+func (pl *PortListener) Capture(w io.Writer) {
+	pl.capture = append(pl.capture, w)
+}
+
 func (pl *PortListener) Open() error {
-	mode := &serial.Mode{
-		BaudRate: pl.baudRate,
+	mode, err := modeFromConfig(pl.cfg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidSettings, err)
+	}
+
+	framer, err := NewFramer(pl.cfg.Framing)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidSettings, err)
 	}
 
-	port, err := serial.Open(pl.portName, mode)
+	device, err := pl.resolve()
 	if err != nil {
 		return err
 	}
+	if prev := pl.Device(); prev != device {
+		releaseDevice(prev, pl)
+	}
 
+	if err := claimDevice(device, pl); err != nil {
+		return err
+	}
+
+	var port serial.Port
+	now := time.Now
+	if pl.cfg.Replay.Enabled() {
+		rp, err := openReplay(pl.cfg.Replay)
+		if err != nil {
+			releaseDevice(device, pl)
+			return fmt.Errorf("%w: %v", errInvalidSettings, err)
+		}
+		port, now = rp, rp.readTime
+	} else if _, _, ok := config.NetworkAddress(device); ok {
+		port, err = openNetwork(device, mode)
+		if err != nil {
+			releaseDevice(device, pl)
+			return err
+		}
+	} else {
+		port, err = serial.Open(device, mode)
+		if err != nil {
+			releaseDevice(device, pl)
+			return err
+		}
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.closed {
+		port.Close()
+		releaseDevice(device, pl)
+		return errListenerClosed
+	}
+	pl.device = device
 	pl.port = port
-	pl.reader = bufio.NewReader(port)
+	var src serial.Port = port
+	if len(pl.capture) > 0 {
+		cp := &capturePort{Port: port, name: pl.cfg.Name}
+		for _, w := range pl.capture {
+			cp.tees = append(cp.tees, &tee{w: w})
+		}
+		src = cp
+	}
+	pl.flow = newFlowControl(src, pl.cfg.FlowControl)
+	pl.arrivals = &arrivalReader{r: pl.flow.reader(), now: now, total: &pl.bytesRead}
+	pl.reader = bufio.NewReader(pl.arrivals)
+	pl.framer = framer
+	return nil
+}
+
+var errListenerClosed = errors.New("port listener is closed")
+
+// ErrNotConnected is returned by Write while the port is not open.
+var ErrNotConnected = errors.New("port is not connected")
+
+// Write sends data to the device. It is safe to call while Listen is
+// reading; concurrent writes are sent one after another. The data is held
+// back while the flow control of the remote side says it cannot receive,
+// until ctx is done.
+func (pl *PortListener) Write(ctx context.Context, data []byte) error {
+	pl.writeMu.Lock()
+	defer pl.writeMu.Unlock()
+
+	pl.mu.Lock()
+	port, flow := pl.port, pl.flow
+	pl.mu.Unlock()
+	if port == nil {
+		return ErrNotConnected
+	}
+
+	if err := flow.waitClearToSend(ctx); err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n, err := port.Write(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
 	return nil
 }
 
+// dtrPulse is how long PulseDTR holds DTR in the opposite state.
+const dtrPulse = 250 * time.Millisecond
+
+// PulseDTR drops DTR for a moment and restores it, which resets many
+// devices; if DTR is configured off, it is raised instead.
+func (pl *PortListener) PulseDTR() error {
+	pl.mu.Lock()
+	port := pl.port
+	pl.mu.Unlock()
+	if port == nil {
+		return ErrNotConnected
+	}
+
+	if err := port.SetDTR(!pl.cfg.DTR); err != nil {
+		return err
+	}
+	time.Sleep(dtrPulse)
+	return port.SetDTR(pl.cfg.DTR)
+}
+
+// resolve returns the path to open. A port matched by USB IDs follows the
+// adapter to whatever path it currently has; the configured device is the
+// fallback while the adapter is absent. A replayed port has no device; it
+// is claimed by its capture files.
+func (pl *PortListener) resolve() (string, error) {
+	if pl.cfg.Replay.Enabled() {
+		return "replay:" + pl.cfg.Replay.File, nil
+	}
+	if pl.cfg.Match.IsEmpty() {
+		return pl.cfg.Device, nil
+	}
+	device, err := resolveDevice(pl.cfg.Match)
+	if err != nil {
+		if pl.cfg.Device != "" {
+			return pl.cfg.Device, nil
+		}
+		return "", err
+	}
+	return device, nil
+}
+
+// Close closes the port for good and releases the device. A blocked read
+// returns, which ends Listen.
 func (pl *PortListener) Close() error {
-	if pl.port != nil {
-		return pl.port.Close()
+	pl.mu.Lock()
+	pl.closed = true
+	port := pl.port
+	pl.port = nil
+	pl.mu.Unlock()
+
+	releaseDevice(pl.Device(), pl)
+	if pl.State() != StateFailed {
+		pl.setState(StateStopped, nil)
+	}
+	if port != nil {
+		return port.Close()
 	}
 	return nil
 }
 
-func (pl *PortListener) Listen(ctx context.Context, dataChan chan<- string, errorChan chan<- error) {
+// closePort closes a port that was lost, keeping the device claimed for
+// the reconnection.
+func (pl *PortListener) closePort() {
+	pl.mu.Lock()
+	port := pl.port
+	pl.port = nil
+	pl.mu.Unlock()
+
+	if port != nil {
+		port.Close()
+	}
+}
+
+// Listen reads frames until ctx is cancelled or the port fails for good.
+// The port is opened if needed; a lost port is reopened with backoff as
+// long as the error is recoverable. Frames are sent to dataChan, the error
+// that ended listening to errorChan.
+func (pl *PortListener) Listen(ctx context.Context, dataChan chan<- Frame, errorChan chan<- error) {
 	defer pl.Close()
 
+	pl.mu.Lock()
+	opened := pl.port != nil
+	pl.mu.Unlock()
+	if opened {
+		pl.connects.Add(1)
+		pl.setState(StateConnected, nil)
+	} else if err := pl.connect(ctx, StateConnecting, nil); err != nil {
+		if ctx.Err() == nil {
+			errorChan <- err
+		}
+		return
+	}
+
 	for {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			return
-		default:
-			line, err := pl.reader.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					// Port closed, try to reconnect
-					log.Println("Port disconnected, attempting to reconnect...")
-					pl.reconnect(ctx)
-					continue
-				}
+		}
+
+		start := pl.arrivals.consumed(pl.reader)
+		frame, err := pl.framer.ReadFrame(pl.reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			var frameErr *FrameError
+			if errors.As(err, &frameErr) {
+				pl.frameErrors.Add(1)
+				log.Printf("Port %s: %v", pl.Device(), err)
+				continue
+			}
+			if errors.Is(err, ErrEndOfReplay) {
+				log.Printf("Port %s: replay of %s finished", pl.cfg.Name, pl.cfg.Replay.File)
+				errorChan <- err
+				return
+			}
+			// Повторное открытие начало бы воспроизведение заново
+			if !isRecoverable(err) || pl.cfg.Replay.Enabled() {
+				pl.setState(StateFailed, err)
 				errorChan <- err
 				return
 			}
 
-			// Trim any trailing whitespace or line endings
-			line = strings.TrimSpace(line)
-			if line != "" {
-				dataChan <- line
+			log.Printf("Port %s disconnected: %v", pl.Device(), err)
+			if err := pl.connect(ctx, StateReconnecting, err); err != nil {
+				if ctx.Err() == nil {
+					errorChan <- err
+				}
+				return
 			}
+			continue
+		}
+
+		if len(frame) > 0 {
+			end := pl.arrivals.consumed(pl.reader)
+			pl.deliver(ctx, dataChan, Frame{Data: frame, ReceivedAt: pl.arrivals.at(frameStart(start, end, frame))})
 		}
 	}
 }
 
-func (pl *PortListener) reconnect(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+// deliver hands a frame to the consumer. If the consumer is busy the remote
+// side is asked to pause until the frame has been accepted.
+func (pl *PortListener) deliver(ctx context.Context, dataChan chan<- Frame, frame Frame) {
+	select {
+	case dataChan <- frame:
+		return
+	default:
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			err := pl.Open()
-			if err == nil {
-				log.Printf("Successfully reconnected to port %s\n", pl.portName)
-				return
+	pl.flow.pause()
+	defer pl.flow.resume()
+
+	select {
+	case dataChan <- frame:
+	case <-ctx.Done():
+	}
+}
+
+// connect opens the port, retrying with backoff while the errors are
+// recoverable. The first attempt of the initial connection is made right
+// away; after a disconnect the port waits before reopening.
+func (pl *PortListener) connect(ctx context.Context, state State, cause error) error {
+	pl.closePort()
+	pl.setState(state, cause)
+
+	b := &backoff{cfg: pl.cfg.Reconnect}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 || state == StateReconnecting {
+			delay := b.next()
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			case <-pl.wake:
+				timer.Stop()
+			}
+		}
+
+		err := pl.Open()
+		if err == nil {
+			if state == StateReconnecting {
+				log.Printf("Successfully reconnected to port %s", pl.Device())
 			}
-			log.Printf("Reconnection attempt failed: %v\n", err)
+			pl.connects.Add(1)
+			pl.setState(StateConnected, nil)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		pl.stateMu.Lock()
+		pl.attempts = attempt + 1
+		pl.lastErr = err
+		pl.stateMu.Unlock()
+
+		max := pl.cfg.Reconnect.MaxAttempts
+		if !isRecoverable(err) || (max > 0 && attempt+1 >= max) {
+			pl.setState(StateFailed, err)
+			return err
 		}
+		log.Printf("Port %s: connection attempt %d failed: %v", pl.Device(), attempt+1, err)
+	}
+}
+
+func (pl *PortListener) setState(state State, err error) {
+	pl.stateMu.Lock()
+	change := StateChange{State: state, Previous: pl.state, Err: err, At: time.Now()}
+	if state == pl.state && err == nil {
+		pl.stateMu.Unlock()
+		return
+	}
+	pl.state = state
+	pl.stateSince = change.At
+	if err != nil {
+		pl.lastErr = err
+	}
+	if state == StateConnected {
+		pl.attempts = 0
+	}
+	onChange := pl.onStateChange
+	pl.stateMu.Unlock()
+
+	if state != change.Previous {
+		log.Printf("Port %s: %s -> %s", pl.cfg.Name, change.Previous, state)
+	}
+	if onChange != nil {
+		onChange(change)
+	}
+}
+
+// State returns the connection state.
+func (pl *PortListener) State() State {
+	pl.stateMu.Lock()
+	defer pl.stateMu.Unlock()
+	return pl.state
+}
+
+// StateInfo describes the connection for status reports.
+type StateInfo struct {
+	State    State
+	Since    time.Time
+	LastErr  string
+	Attempts int
+}
+
+func (pl *PortListener) StateInfo() StateInfo {
+	pl.stateMu.Lock()
+	defer pl.stateMu.Unlock()
+	info := StateInfo{State: pl.state, Since: pl.stateSince, Attempts: pl.attempts}
+	if pl.lastErr != nil {
+		info.LastErr = pl.lastErr.Error()
+	}
+	return info
+}
+
+// Wake makes a pending reconnect try again right away, e.g. because the
+// device has just been plugged in.
+func (pl *PortListener) Wake() {
+	select {
+	case pl.wake <- struct{}{}:
+	default:
 	}
 }
 
 func (pl *PortListener) Name() string {
-	return pl.portName
+	return pl.Device()
+}
+
+// Device returns the path the port is open at, or the configured device
+// if it has not been opened yet.
+func (pl *PortListener) Device() string {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.device != "" {
+		return pl.device
+	}
+	return pl.cfg.Device
 }
 
 func (pl *PortListener) BaudRate() int {
-	return pl.baudRate
+	return pl.cfg.BaudRate
+}
+
+func (pl *PortListener) Config() config.PortConfig {
+	return pl.cfg
+}
+
+// BytesRead returns the number of bytes read so far, without the XON/XOFF
+// characters of software flow control.
+func (pl *PortListener) BytesRead() uint64 {
+	return pl.bytesRead.Load()
+}
+
+// FrameErrors returns the number of malformed frames dropped so far.
+func (pl *PortListener) FrameErrors() uint64 {
+	return pl.frameErrors.Load()
 }
@@ -0,0 +1,130 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/capture"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+	"go.bug.st/serial"
+)
+
+// ErrEndOfReplay ends Listen when the capture files of a replayed port have
+// been read to the end.
+var ErrEndOfReplay = errors.New("end of replay")
+
+// replayPort is a virtual port that returns the bytes of capture files with
+// the timing they were read with, scaled by the replay speed. Writes are
+// discarded and the modem lines are always up.
+type replayPort struct {
+	mu    sync.Mutex // guards r against Close during Read
+	r     *capture.Reader
+	speed float64
+	fast  bool
+
+	origin  time.Time // capture time of the first record
+	started time.Time // wall time the first record was returned
+
+	pending []byte
+	at      time.Time // capture time of the last bytes returned
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func openReplay(cfg config.ReplayConfig) (*replayPort, error) {
+	r, err := capture.Open(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	return &replayPort{r: r, speed: cfg.Speed, fast: cfg.Fast, closed: make(chan struct{})}, nil
+}
+
+func (rp *replayPort) Read(p []byte) (int, error) {
+	select {
+	case <-rp.closed:
+		return 0, os.ErrClosed
+	default:
+	}
+
+	for len(rp.pending) == 0 {
+		rp.mu.Lock()
+		t, data, err := rp.r.Next()
+		rp.mu.Unlock()
+		if err == io.EOF {
+			return 0, ErrEndOfReplay
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := rp.wait(t); err != nil {
+			return 0, err
+		}
+		rp.pending, rp.at = data, t
+	}
+
+	n := copy(p, rp.pending)
+	rp.pending = rp.pending[n:]
+	return n, nil
+}
+
+// wait sleeps until the record read at t is due.
+func (rp *replayPort) wait(t time.Time) error {
+	if rp.started.IsZero() {
+		rp.origin, rp.started = t, time.Now()
+		return nil
+	}
+	if rp.fast {
+		return nil
+	}
+
+	due := rp.started.Add(time.Duration(float64(t.Sub(rp.origin)) / rp.speed))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-rp.closed:
+		return os.ErrClosed
+	}
+}
+
+// readTime returns the capture time of the bytes returned last, which
+// frames read from the replay are stamped with.
+func (rp *replayPort) readTime() time.Time {
+	return rp.at
+}
+
+func (rp *replayPort) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (rp *replayPort) Close() error {
+	var err error
+	rp.closeOnce.Do(func() {
+		close(rp.closed)
+		rp.mu.Lock()
+		err = rp.r.Close()
+		rp.mu.Unlock()
+	})
+	return err
+}
+
+func (rp *replayPort) SetMode(mode *serial.Mode) error      { return nil }
+func (rp *replayPort) Drain() error                         { return nil }
+func (rp *replayPort) ResetInputBuffer() error              { return nil }
+func (rp *replayPort) ResetOutputBuffer() error             { return nil }
+func (rp *replayPort) SetDTR(dtr bool) error                { return nil }
+func (rp *replayPort) SetRTS(rts bool) error                { return nil }
+func (rp *replayPort) SetReadTimeout(t time.Duration) error { return nil }
+func (rp *replayPort) Break(d time.Duration) error          { return nil }
+func (rp *replayPort) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{CTS: true, DSR: true, RI: false, DCD: true}, nil
+}
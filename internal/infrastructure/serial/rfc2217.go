@@ -0,0 +1,459 @@
+package serial
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Telnet commands and options used by RFC 2217.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44
+)
+
+// COM-PORT-OPTION commands sent by the client; the server answers with the
+// same command plus 100.
+const (
+	cpSetBaudRate    = 1
+	cpSetDataSize    = 2
+	cpSetParity      = 3
+	cpSetStopSize    = 4
+	cpSetControl     = 5
+	cpNotifyModem    = 7
+	cpPurgeData      = 12
+	cpServerResponse = 100
+
+	controlNoFlow   = 1
+	controlBreakOn  = 5
+	controlBreakOff = 6
+	controlDTROn    = 8
+	controlDTROff   = 9
+	controlRTSOn    = 11
+	controlRTSOff   = 12
+)
+
+// States of the telnet decoder.
+const (
+	stData = iota
+	stIAC
+	stOption // after WILL, WONT, DO or DONT
+	stSub
+	stSubIAC
+)
+
+// rfc2217Port is a telnet connection to an RFC 2217 server. The line
+// settings and modem lines are set on the remote port through the
+// COM-PORT-OPTION; the data is escaped telnet.
+type rfc2217Port struct {
+	conn   net.Conn
+	device string
+
+	writeMu sync.Mutex
+
+	// Состояние декодера; его использует только читающая горутина
+	state   int
+	command byte
+	sub     []byte
+	raw     []byte
+	pending []byte // data received while negotiating
+
+	mu        sync.Mutex
+	modem     byte
+	hasModem  bool
+	refused   bool   // the server will not do COM port control
+	baud      uint32 // baud rate sent
+	requested []byte // values of the other settings sent, by command
+	answered  []bool // the server has answered the setting, by command
+	mismatch  error  // the first setting the server did not take as sent
+}
+
+// Line settings the server must confirm, with their names for the log.
+var lineSettings = []struct {
+	code byte
+	name string
+}{
+	{cpSetBaudRate, "baud rate"},
+	{cpSetDataSize, "data bits"},
+	{cpSetParity, "parity"},
+	{cpSetStopSize, "stop bits"},
+}
+
+func newRFC2217Port(conn net.Conn, device string) *rfc2217Port {
+	return &rfc2217Port{
+		conn:      conn,
+		device:    device,
+		requested: make([]byte, cpSetStopSize+1),
+		answered:  make([]bool, cpSetStopSize+1),
+	}
+}
+
+// negotiate enables the COM port option and sets the line, then waits a
+// little for the server to confirm every setting. A server that set
+// something else fails the open with errInvalidSettings; one that stays
+// silent fails it too, and the open is retried like any other connection
+// error.
+func (rp *rfc2217Port) negotiate(mode *serial.Mode) error {
+	if err := rp.send(
+		telnetIAC, telnetWILL, optComPort,
+		telnetIAC, telnetWILL, optBinary,
+		telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA,
+		telnetIAC, telnetDO, optSGA,
+	); err != nil {
+		return err
+	}
+	if err := rp.control(controlNoFlow); err != nil {
+		return err
+	}
+	if err := rp.SetMode(mode); err != nil {
+		return err
+	}
+
+	rp.conn.SetReadDeadline(time.Now().Add(negotiateWait))
+	defer rp.conn.SetReadDeadline(time.Time{})
+	buf := make([]byte, 512)
+	for {
+		rp.mu.Lock()
+		refused, mismatch, missing := rp.refused, rp.mismatch, rp.unanswered()
+		rp.mu.Unlock()
+		if refused {
+			return fmt.Errorf("%w: %s does not support RFC 2217 COM port control", errInvalidSettings, rp.device)
+		}
+		if mismatch != nil {
+			return mismatch
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		n, err := rp.conn.Read(buf)
+		rp.pending = append(rp.pending, buf[:rp.decode(buf[:n], buf)]...)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			// Порт мог остаться с чужими настройками: соединение
+			// закрывается и повторяется с задержкой
+			return fmt.Errorf("the RFC 2217 server at %s did not confirm the %s within %s",
+				rp.device, strings.Join(missing, ", "), negotiateWait)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (rp *rfc2217Port) Read(p []byte) (int, error) {
+	if len(rp.pending) > 0 {
+		n := copy(p, rp.pending)
+		rp.pending = rp.pending[n:]
+		return n, nil
+	}
+
+	if cap(rp.raw) < len(p) {
+		rp.raw = make([]byte, len(p))
+	}
+	for {
+		n, err := rp.conn.Read(rp.raw[:len(p)])
+		// Декодированные данные не длиннее сырых
+		m := rp.decode(rp.raw[:n], p)
+		if m > 0 || err != nil {
+			return m, err
+		}
+	}
+}
+
+// decode strips the telnet commands from src, handles them and writes the
+// data to dst, which may be src itself. It returns the length of the data.
+func (rp *rfc2217Port) decode(src, dst []byte) int {
+	n := 0
+	for _, b := range src {
+		switch rp.state {
+		case stData:
+			if b == telnetIAC {
+				rp.state = stIAC
+			} else {
+				dst[n] = b
+				n++
+			}
+		case stIAC:
+			switch b {
+			case telnetIAC:
+				dst[n] = b
+				n++
+				rp.state = stData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				rp.command = b
+				rp.state = stOption
+			case telnetSB:
+				rp.sub = rp.sub[:0]
+				rp.state = stSub
+			default:
+				rp.state = stData
+			}
+		case stOption:
+			rp.option(rp.command, b)
+			rp.state = stData
+		case stSub:
+			if b == telnetIAC {
+				rp.state = stSubIAC
+			} else {
+				rp.sub = append(rp.sub, b)
+			}
+		case stSubIAC:
+			switch b {
+			case telnetIAC:
+				rp.sub = append(rp.sub, b)
+				rp.state = stSub
+			case telnetSE:
+				rp.subnegotiation(rp.sub)
+				rp.state = stData
+			default:
+				rp.state = stData
+			}
+		}
+	}
+	return n
+}
+
+// option answers the option negotiation of the server. The options the
+// client wants were offered and requested up front, so only the others
+// are answered, which cannot start a negotiation loop.
+func (rp *rfc2217Port) option(command, opt byte) {
+	switch command {
+	case telnetDO:
+		if opt != optComPort && opt != optBinary && opt != optSGA {
+			rp.send(telnetIAC, telnetWONT, opt)
+		}
+	case telnetWILL:
+		if opt != optBinary && opt != optSGA {
+			rp.send(telnetIAC, telnetDONT, opt)
+		}
+	case telnetDONT:
+		if opt == optComPort {
+			rp.mu.Lock()
+			rp.refused = true
+			rp.mu.Unlock()
+		}
+	}
+}
+
+func (rp *rfc2217Port) subnegotiation(sub []byte) {
+	if len(sub) < 2 || sub[0] != optComPort {
+		return
+	}
+	code, value := sub[1], sub[2:]
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	switch code {
+	case cpServerResponse + cpSetBaudRate:
+		rp.answered[cpSetBaudRate] = true
+		if len(value) == 4 {
+			if got := binary.BigEndian.Uint32(value); got != rp.baud {
+				rp.reject(fmt.Sprintf("set the baud rate to %d instead of %d", got, rp.baud))
+			}
+		}
+	case cpServerResponse + cpSetDataSize, cpServerResponse + cpSetParity, cpServerResponse + cpSetStopSize:
+		c := code - cpServerResponse
+		rp.answered[c] = true
+		if len(value) == 1 && rp.requested[c] != 0 && value[0] != rp.requested[c] {
+			rp.reject(fmt.Sprintf("set the %s to %d instead of %d", settingName(c), value[0], rp.requested[c]))
+		}
+	case cpServerResponse + cpNotifyModem:
+		if len(value) == 1 {
+			rp.modem, rp.hasModem = value[0], true
+		}
+	}
+}
+
+// reject records a setting the server did not take; the first one fails
+// negotiate. rp.mu is held.
+func (rp *rfc2217Port) reject(what string) {
+	if rp.mismatch == nil {
+		rp.mismatch = fmt.Errorf("%w: the RFC 2217 server at %s %s", errInvalidSettings, rp.device, what)
+	}
+}
+
+// unanswered returns the names of the line settings the server has not
+// confirmed yet. rp.mu is held.
+func (rp *rfc2217Port) unanswered() []string {
+	var names []string
+	for _, s := range lineSettings {
+		if !rp.answered[s.code] {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+func settingName(code byte) string {
+	for _, s := range lineSettings {
+		if s.code == code {
+			return s.name
+		}
+	}
+	return fmt.Sprintf("setting %d", code)
+}
+
+// send writes telnet commands as they are.
+func (rp *rfc2217Port) send(b ...byte) error {
+	rp.writeMu.Lock()
+	defer rp.writeMu.Unlock()
+	_, err := rp.conn.Write(b)
+	return err
+}
+
+// comPort sends a COM-PORT-OPTION command.
+func (rp *rfc2217Port) comPort(code byte, value ...byte) error {
+	msg := []byte{telnetIAC, telnetSB, optComPort, code}
+	msg = append(msg, escapeIAC(value)...)
+	msg = append(msg, telnetIAC, telnetSE)
+	return rp.send(msg...)
+}
+
+func (rp *rfc2217Port) control(value byte) error {
+	return rp.comPort(cpSetControl, value)
+}
+
+func (rp *rfc2217Port) Write(p []byte) (int, error) {
+	rp.writeMu.Lock()
+	defer rp.writeMu.Unlock()
+	if _, err := rp.conn.Write(escapeIAC(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// escapeIAC doubles the bytes that would be taken for telnet commands.
+func escapeIAC(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func (rp *rfc2217Port) SetMode(mode *serial.Mode) error {
+	var parity, stop byte
+	switch mode.Parity {
+	case serial.NoParity:
+		parity = 1
+	case serial.OddParity:
+		parity = 2
+	case serial.EvenParity:
+		parity = 3
+	case serial.MarkParity:
+		parity = 4
+	case serial.SpaceParity:
+		parity = 5
+	}
+	switch mode.StopBits {
+	case serial.OneStopBit:
+		stop = 1
+	case serial.TwoStopBits:
+		stop = 2
+	case serial.OnePointFiveStopBits:
+		stop = 3
+	}
+
+	rp.mu.Lock()
+	rp.baud = uint32(mode.BaudRate)
+	rp.requested[cpSetDataSize] = byte(mode.DataBits)
+	rp.requested[cpSetParity] = parity
+	rp.requested[cpSetStopSize] = stop
+	for i := range rp.answered {
+		rp.answered[i] = false
+	}
+	rp.mismatch = nil
+	rp.mu.Unlock()
+
+	var baud [4]byte
+	binary.BigEndian.PutUint32(baud[:], uint32(mode.BaudRate))
+	if err := rp.comPort(cpSetBaudRate, baud[:]...); err != nil {
+		return err
+	}
+	if err := rp.comPort(cpSetDataSize, byte(mode.DataBits)); err != nil {
+		return err
+	}
+	if err := rp.comPort(cpSetParity, parity); err != nil {
+		return err
+	}
+	if err := rp.comPort(cpSetStopSize, stop); err != nil {
+		return err
+	}
+	if bits := mode.InitialStatusBits; bits != nil {
+		if err := rp.SetDTR(bits.DTR); err != nil {
+			return err
+		}
+		return rp.SetRTS(bits.RTS)
+	}
+	return nil
+}
+
+func (rp *rfc2217Port) SetDTR(dtr bool) error {
+	if dtr {
+		return rp.control(controlDTROn)
+	}
+	return rp.control(controlDTROff)
+}
+
+func (rp *rfc2217Port) SetRTS(rts bool) error {
+	if rts {
+		return rp.control(controlRTSOn)
+	}
+	return rp.control(controlRTSOff)
+}
+
+func (rp *rfc2217Port) Break(d time.Duration) error {
+	if err := rp.control(controlBreakOn); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return rp.control(controlBreakOff)
+}
+
+func (rp *rfc2217Port) ResetInputBuffer() error {
+	return rp.comPort(cpPurgeData, 1)
+}
+
+func (rp *rfc2217Port) ResetOutputBuffer() error {
+	return rp.comPort(cpPurgeData, 2)
+}
+
+// GetModemStatusBits returns the modem lines the server last notified;
+// until it does they are reported as up.
+func (rp *rfc2217Port) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if !rp.hasModem {
+		return &serial.ModemStatusBits{CTS: true, DSR: true, DCD: true}, nil
+	}
+	return &serial.ModemStatusBits{
+		CTS: rp.modem&0x10 != 0,
+		DSR: rp.modem&0x20 != 0,
+		RI:  rp.modem&0x40 != 0,
+		DCD: rp.modem&0x80 != 0,
+	}, nil
+}
+
+func (rp *rfc2217Port) Drain() error                         { return nil }
+func (rp *rfc2217Port) SetReadTimeout(t time.Duration) error { return nil }
+func (rp *rfc2217Port) Close() error                         { return rp.conn.Close() }
@@ -0,0 +1,128 @@
+package serial
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"go.bug.st/serial"
+)
+
+// fakeRFC2217 answers the COM-PORT-OPTION settings the client sends.
+// answer returns the value to reply with, or nil to stay silent.
+func fakeRFC2217(conn net.Conn, answer func(code byte, value []byte) []byte) {
+	buf := make([]byte, 1024)
+	var pending []byte
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		pending = append(pending, buf[:n]...)
+		for {
+			start := strings.Index(string(pending), string([]byte{telnetIAC, telnetSB, optComPort}))
+			if start < 0 {
+				break
+			}
+			end := strings.Index(string(pending[start:]), string([]byte{telnetIAC, telnetSE}))
+			if end < 0 {
+				break
+			}
+			sub := pending[start+3 : start+end]
+			pending = pending[start+end+2:]
+			if len(sub) == 0 || sub[0] > cpSetStopSize {
+				continue
+			}
+			reply := answer(sub[0], sub[1:])
+			if reply == nil {
+				continue
+			}
+			msg := append([]byte{telnetIAC, telnetSB, optComPort, sub[0] + cpServerResponse}, escapeIAC(reply)...)
+			conn.Write(append(msg, telnetIAC, telnetSE))
+		}
+	}
+}
+
+func TestRFC2217Negotiate(t *testing.T) {
+	echo := func(code byte, value []byte) []byte { return value }
+	tests := []struct {
+		name    string
+		answer  func(code byte, value []byte) []byte
+		invalid bool   // the error must be errInvalidSettings
+		errText string // part of the error, "" if there is none
+	}{
+		{name: "confirmed", answer: echo},
+		{
+			name: "other baud rate",
+			answer: func(code byte, value []byte) []byte {
+				if code == cpSetBaudRate {
+					return binary.BigEndian.AppendUint32(nil, 9600)
+				}
+				return value
+			},
+			invalid: true,
+			errText: "baud rate to 9600 instead of 19200",
+		},
+		{
+			name: "other parity",
+			answer: func(code byte, value []byte) []byte {
+				if code == cpSetParity {
+					return []byte{1}
+				}
+				return value
+			},
+			invalid: true,
+			errText: "parity to 1 instead of 3",
+		},
+		{
+			name: "stop bits not confirmed",
+			answer: func(code byte, value []byte) []byte {
+				if code == cpSetStopSize {
+					return nil
+				}
+				return value
+			},
+			errText: "did not confirm the stop bits",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			go func() {
+				server, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer server.Close()
+				fakeRFC2217(server, tt.answer)
+			}()
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			rp := newRFC2217Port(client, "rfc2217://test:2217")
+			err = rp.negotiate(&serial.Mode{BaudRate: 19200, DataBits: 8, Parity: serial.EvenParity, StopBits: serial.OneStopBit})
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("got error %v, want one with %q", err, tt.errText)
+			}
+			if errors.Is(err, errInvalidSettings) != tt.invalid || isRecoverable(err) == tt.invalid {
+				t.Fatalf("error %v: invalid settings %v, recoverable %v", err, errors.Is(err, errInvalidSettings), isRecoverable(err))
+			}
+		})
+	}
+}
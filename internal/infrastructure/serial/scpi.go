@@ -0,0 +1,228 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notice reports what an instrument said besides measurements.
+type Notice struct {
+	Kind    string
+	Message string
+	At      time.Time
+}
+
+const (
+	NoticeIdentity = "identity"         // answer to *IDN?
+	NoticeCommand  = "command"          // init command sent, with the answer to a query
+	NoticeError    = "instrument_error" // entry of the SYST:ERR? queue
+)
+
+// maxErrorReads bounds the SYST:ERR? reads in a row, in case the
+// instrument never reports an empty queue.
+const maxErrorReads = 20
+
+// PollSCPI drives a SCPI instrument. After every connect the instrument is
+// identified and initialized, then the measurement queries are sent every
+// interval and their answers go to dataChan as one "name=value ..." record.
+// Identity, init commands and instrument errors go to noticeChan,
+// unanswered queries to timeoutChan; a round with an unanswered query is
+// abandoned. Errors end polling as in Listen.
+func (pl *PortListener) PollSCPI(ctx context.Context, dataChan chan<- Frame, timeoutChan chan<- PollTimeout, noticeChan chan<- Notice, errorChan chan<- error) {
+	frames := make(chan Frame)
+	go pl.Listen(ctx, frames, errorChan)
+
+	s := &scpiSession{pl: pl, frames: frames, timeoutChan: timeoutChan, noticeChan: noticeChan}
+	ticker := time.NewTicker(time.Duration(pl.cfg.SCPI.Interval))
+	defer ticker.Stop()
+
+	var ready uint64 // connect the instrument was initialized on
+	for {
+		if pl.State() == StateConnected {
+			connect := pl.connects.Load()
+			// Прибор мог быть выключен: после переподключения инициализируем заново
+			if connect != ready && s.setup(ctx) {
+				ready = connect
+			}
+			if connect == ready {
+				s.measure(ctx, dataChan)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case frame := <-frames:
+			log.Printf("Port %s: dropped unsolicited frame %q", pl.cfg.Name, frame.Data)
+		case <-ticker.C:
+		}
+	}
+}
+
+type scpiSession struct {
+	pl          *PortListener
+	frames      <-chan Frame
+	timeoutChan chan<- PollTimeout
+	noticeChan  chan<- Notice
+}
+
+// setup identifies the instrument and sends the init commands. It returns
+// false if the instrument did not answer.
+func (s *scpiSession) setup(ctx context.Context) bool {
+	idn, _, ok := s.ask(ctx, "*IDN?")
+	if !ok {
+		return false
+	}
+	s.notify(ctx, NoticeIdentity, idn)
+
+	for _, cmd := range s.pl.cfg.SCPI.Init {
+		if !strings.Contains(cmd, "?") {
+			if !s.send(ctx, cmd) {
+				return false
+			}
+			s.notify(ctx, NoticeCommand, cmd)
+			continue
+		}
+		answer, _, ok := s.ask(ctx, cmd)
+		if !ok {
+			return false
+		}
+		s.notify(ctx, NoticeCommand, cmd+" -> "+answer)
+	}
+	return s.checkErrors(ctx)
+}
+
+// measure sends every query once and passes the answers on as a record.
+// It returns false if a query was left unanswered.
+func (s *scpiSession) measure(ctx context.Context, dataChan chan<- Frame) bool {
+	queries := s.pl.cfg.SCPI.Queries
+	pairs := make([]string, 0, len(queries))
+	var receivedAt time.Time // the record is stamped with its first answer
+	for i, q := range queries {
+		answer, at, ok := s.ask(ctx, q.Query)
+		if !ok {
+			return false
+		}
+		if i == 0 {
+			receivedAt = at
+		}
+		pairs = append(pairs, scpiPairs(q.Name, answer)...)
+	}
+
+	select {
+	case dataChan <- Frame{Data: []byte(strings.Join(pairs, " ")), ReceivedAt: receivedAt}:
+	case <-ctx.Done():
+		return false
+	}
+	return s.checkErrors(ctx)
+}
+
+// checkErrors reads the error queue until it is empty and reports each
+// entry.
+func (s *scpiSession) checkErrors(ctx context.Context) bool {
+	if !s.pl.cfg.SCPI.ChecksErrors() {
+		return true
+	}
+	for i := 0; i < maxErrorReads; i++ {
+		answer, _, ok := s.ask(ctx, "SYST:ERR?")
+		if !ok {
+			return false
+		}
+		// Ответ вида `-113,"Undefined header"`, код 0 - очередь пуста
+		code, _, _ := strings.Cut(answer, ",")
+		if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil && n == 0 {
+			return true
+		}
+		s.notify(ctx, NoticeError, answer)
+	}
+	return true
+}
+
+// send writes a command that has no answer.
+func (s *scpiSession) send(ctx context.Context, cmd string) bool {
+	writeCtx, cancel := context.WithTimeout(ctx, time.Duration(s.pl.cfg.SCPI.Timeout))
+	defer cancel()
+	if err := s.pl.Write(writeCtx, []byte(cmd+s.pl.cfg.CommandEnding)); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Port %s: failed to send %q: %v", s.pl.cfg.Name, cmd, err)
+		}
+		return false
+	}
+	return true
+}
+
+// ask sends a query and returns its answer and the time it arrived. A
+// query left unanswered is reported to timeoutChan.
+func (s *scpiSession) ask(ctx context.Context, query string) (string, time.Time, bool) {
+	// Запоздавший ответ на прошлый запрос не должен попасть в этот
+	for drained := false; !drained; {
+		select {
+		case frame := <-s.frames:
+			log.Printf("Port %s: dropped unsolicited frame %q", s.pl.cfg.Name, frame.Data)
+		default:
+			drained = true
+		}
+	}
+
+	sentAt := time.Now()
+	if !s.send(ctx, query) {
+		return "", time.Time{}, false
+	}
+
+	timeout := time.Duration(s.pl.cfg.SCPI.Timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case frame := <-s.frames:
+		return strings.TrimSpace(string(frame.Data)), frame.ReceivedAt, true
+	case <-timer.C:
+		s.pl.pollTimeouts.Add(1)
+		select {
+		case s.timeoutChan <- PollTimeout{
+			Query:  query,
+			SentAt: sentAt,
+			Until:  time.Now(),
+			Reason: fmt.Sprintf("no response within %s", timeout),
+		}:
+		case <-ctx.Done():
+		}
+		return "", time.Time{}, false
+	case <-ctx.Done():
+		return "", time.Time{}, false
+	}
+}
+
+func (s *scpiSession) notify(ctx context.Context, kind, message string) {
+	select {
+	case s.noticeChan <- Notice{Kind: kind, Message: message, At: time.Now()}:
+	case <-ctx.Done():
+	}
+}
+
+// scpiPairs turns the answer to a query into key=value pairs. Several
+// comma-separated values are numbered from 1.
+func scpiPairs(name, answer string) []string {
+	values := strings.Split(answer, ",")
+	if len(values) == 1 {
+		return []string{name + "=" + scpiValue(values[0])}
+	}
+	pairs := make([]string, len(values))
+	for i, v := range values {
+		pairs[i] = fmt.Sprintf("%s.%d=%s", name, i+1, scpiValue(v))
+	}
+	return pairs
+}
+
+// scpiValue unquotes a string answer and keeps it in one field of the
+// record.
+func scpiValue(v string) string {
+	v = strings.Trim(strings.TrimSpace(v), `"'`)
+	return strings.Join(strings.Fields(v), "_")
+}
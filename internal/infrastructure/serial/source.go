@@ -0,0 +1,115 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// DataSource is where a port gets its frames from. PortListener reads them
+// from a serial port; other sources may read sockets or files, or be fakes
+// in tests.
+type DataSource interface {
+	Config() config.PortConfig
+	Open() error
+	// Listen reads frames until ctx is cancelled or the source fails for
+	// good, opening the source if needed. Frames are sent to dataChan, the
+	// error that ended listening to errorChan.
+	Listen(ctx context.Context, dataChan chan<- Frame, errorChan chan<- error)
+	// Write sends data to the device.
+	Write(ctx context.Context, data []byte) error
+	// Close ends Listen and releases the source for good.
+	Close() error
+	Status() SourceStatus
+	// OnStateChange sets a function called on every state change. It must
+	// be set before Listen.
+	OnStateChange(fn func(StateChange))
+}
+
+// SourceStatus describes a source for status reports.
+type SourceStatus struct {
+	Device       string // where the source reads from
+	State        StateInfo
+	BytesRead    uint64
+	FrameErrors  uint64
+	PollTimeouts uint64
+}
+
+// Poller is a DataSource that can query the device itself instead of
+// waiting for it to send data.
+type Poller interface {
+	Poll(ctx context.Context, dataChan chan<- Frame, timeoutChan chan<- PollTimeout, errorChan chan<- error)
+	PollModbus(ctx context.Context, dataChan chan<- Frame, timeoutChan chan<- PollTimeout, errorChan chan<- error)
+	PollSCPI(ctx context.Context, dataChan chan<- Frame, timeoutChan chan<- PollTimeout, noticeChan chan<- Notice, errorChan chan<- error)
+}
+
+// Waker is a DataSource that can be asked to retry a lost connection now.
+type Waker interface {
+	Wake()
+}
+
+// DTRPulser is a DataSource that can reset the device with its DTR line.
+type DTRPulser interface {
+	PulseDTR() error
+}
+
+// Capturer is a DataSource that can tee the raw bytes it reads into w. It
+// must be called before the source is opened, once for every writer.
+type Capturer interface {
+	Capture(w io.Writer)
+}
+
+// SourceFactory creates a data source for a port.
+type SourceFactory func(cfg config.PortConfig) (DataSource, error)
+
+var sources = make(map[string]SourceFactory)
+
+// RegisterSource makes a kind of data source available under the given
+// name, which ports select with their source setting.
+func RegisterSource(name string, factory SourceFactory) {
+	sources[name] = factory
+}
+
+// SourceNames returns the names of all registered sources.
+func SourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewSource creates the data source selected by cfg.
+func NewSource(cfg config.PortConfig) (DataSource, error) {
+	name := cfg.Source
+	if name == "" {
+		name = config.SourceSerial
+	}
+	factory, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q. Must be one of %s", name, strings.Join(SourceNames(), ", "))
+	}
+	return factory(cfg)
+}
+
+func init() {
+	RegisterSource(config.SourceSerial, func(cfg config.PortConfig) (DataSource, error) {
+		return NewPortListener(cfg), nil
+	})
+}
+
+// Status reports the device, connection state and error counters.
+func (pl *PortListener) Status() SourceStatus {
+	return SourceStatus{
+		Device:       pl.Device(),
+		State:        pl.StateInfo(),
+		BytesRead:    pl.BytesRead(),
+		FrameErrors:  pl.FrameErrors(),
+		PollTimeouts: pl.PollTimeouts(),
+	}
+}
@@ -0,0 +1,71 @@
+package serial
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+	"go.bug.st/serial"
+)
+
+// State is the connection state of a PortListener.
+type State string
+
+const (
+	StateStopped      State = "stopped"
+	StateConnecting   State = "connecting"
+	StateConnected    State = "connected"
+	StateReconnecting State = "reconnecting"
+	StateFailed       State = "failed"
+)
+
+// StateChange is passed to the state handler of a PortListener.
+type StateChange struct {
+	State    State
+	Previous State
+	Err      error // the error that caused the change, if any
+	At       time.Time
+}
+
+// errInvalidSettings marks errors that reopening the port cannot fix.
+var errInvalidSettings = errors.New("invalid port settings")
+
+// isRecoverable reports whether reopening the port may help after err.
+// Anything but a configuration problem is worth another attempt: the
+// device may come back, be released by another program, or get its
+// permissions fixed.
+func isRecoverable(err error) bool {
+	if errors.Is(err, errInvalidSettings) {
+		return false
+	}
+	var portErr *serial.PortError
+	if errors.As(err, &portErr) {
+		switch portErr.Code() {
+		case serial.InvalidSpeed, serial.InvalidDataBits, serial.InvalidParity,
+			serial.InvalidStopBits, serial.InvalidTimeoutValue, serial.FunctionNotImplemented:
+			return false
+		}
+	}
+	return true
+}
+
+// backoff computes the delays between reconnection attempts.
+type backoff struct {
+	cfg     config.ReconnectConfig
+	attempt int
+}
+
+func (b *backoff) next() time.Duration {
+	delay := float64(b.cfg.InitialDelay) * math.Pow(b.cfg.Multiplier, float64(b.attempt))
+	if max := float64(b.cfg.MaxDelay); delay > max {
+		delay = max
+	}
+	b.attempt++
+
+	if b.cfg.Jitter > 0 {
+		delay *= 1 + b.cfg.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
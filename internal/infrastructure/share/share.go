@@ -0,0 +1,210 @@
+// Package share serves the live byte stream of a port to TCP clients, so
+// that other tools can watch an instrument while the logger keeps the
+// port to itself.
+package share
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// clientBuffer is how many reads a client may lag behind before it is
+// disconnected; dropping bytes instead would garble its stream.
+const clientBuffer = 256
+
+// ErrNoDevice is reported to a writing client while the port is not
+// collecting data.
+var ErrNoDevice = errors.New("port is not collecting data")
+
+// Server accepts clients and copies to each of them everything written to
+// it.
+type Server struct {
+	cfg  config.ShareConfig
+	name string
+	ln   net.Listener
+
+	mu      sync.Mutex
+	clients []*client // in the order they connected
+	writer  *client   // the client allowed to write in the exclusive mode
+	target  func([]byte) error
+	closed  bool
+}
+
+type client struct {
+	conn net.Conn
+	out  chan []byte
+	once sync.Once
+}
+
+// Listen starts serving the port called name on the configured address.
+func Listen(cfg config.ShareConfig, name string) (*Server, error) {
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to share port %s: %w", name, err)
+	}
+	s := &Server{cfg: cfg, name: name, ln: ln}
+	go s.accept()
+	log.Printf("Port %s is shared on %s (%s)", name, ln.Addr(), cfg.Mode)
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Port %s: share server stopped: %v", s.name, err)
+			}
+			return
+		}
+
+		c := &client{conn: conn, out: make(chan []byte, clientBuffer)}
+		s.mu.Lock()
+		if s.closed || len(s.clients) >= s.cfg.MaxClients {
+			s.mu.Unlock()
+			log.Printf("Port %s: share client %s refused, %d clients already connected", s.name, conn.RemoteAddr(), s.cfg.MaxClients)
+			conn.Close()
+			continue
+		}
+		s.clients = append(s.clients, c)
+		if s.cfg.Mode == config.ShareExclusive && s.writer == nil {
+			s.writer = c
+		}
+		writer := s.writer == c
+		s.mu.Unlock()
+
+		log.Printf("Port %s: share client %s connected (writer: %t)", s.name, conn.RemoteAddr(), writer)
+		go s.send(c)
+		go s.receive(c)
+	}
+}
+
+// send copies the stream to the client.
+func (s *Server) send(c *client) {
+	for data := range c.out {
+		if _, err := c.conn.Write(data); err != nil {
+			s.drop(c, err)
+			return
+		}
+	}
+}
+
+// receive passes what the writing client sends to the device and discards
+// what the others send.
+func (s *Server) receive(c *client) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := c.conn.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			writer, target := s.writer == c, s.target
+			s.mu.Unlock()
+			switch {
+			case !writer:
+			case target == nil:
+				log.Printf("Port %s: share client %s: %v, %d bytes discarded", s.name, c.conn.RemoteAddr(), ErrNoDevice, n)
+			default:
+				data := append([]byte(nil), buf[:n]...)
+				if err := target(data); err != nil {
+					log.Printf("Port %s: failed to write for share client %s: %v", s.name, c.conn.RemoteAddr(), err)
+				}
+			}
+		}
+		if err != nil {
+			s.drop(c, nil)
+			return
+		}
+	}
+}
+
+// drop disconnects the client. In the exclusive mode the write access
+// passes to the client that has been connected longest.
+func (s *Server) drop(c *client, reason error) {
+	c.once.Do(func() {
+		c.conn.Close()
+
+		s.mu.Lock()
+		for i, other := range s.clients {
+			if other == c {
+				s.clients = append(s.clients[:i], s.clients[i+1:]...)
+				break
+			}
+		}
+		close(c.out)
+		if s.writer == c {
+			s.writer = nil
+			if len(s.clients) > 0 {
+				s.writer = s.clients[0]
+				log.Printf("Port %s: share client %s may write now", s.name, s.writer.conn.RemoteAddr())
+			}
+		}
+		s.mu.Unlock()
+
+		if reason != nil {
+			log.Printf("Port %s: share client %s dropped: %v", s.name, c.conn.RemoteAddr(), reason)
+		} else {
+			log.Printf("Port %s: share client %s disconnected", s.name, c.conn.RemoteAddr())
+		}
+	})
+}
+
+// Write sends p to every client. It never blocks: a client that cannot
+// keep up is disconnected.
+func (s *Server) Write(p []byte) (int, error) {
+	data := append([]byte(nil), p...)
+
+	s.mu.Lock()
+	var slow []*client
+	for _, c := range s.clients {
+		select {
+		case c.out <- data:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range slow {
+		go s.drop(c, errors.New("client is too slow"))
+	}
+	return len(p), nil
+}
+
+// SetTarget sets the function that writes to the device for the writing
+// client; nil while the port is not collecting data.
+func (s *Server) SetTarget(fn func([]byte) error) {
+	s.mu.Lock()
+	s.target = fn
+	s.mu.Unlock()
+}
+
+// Clients returns the number of connected clients.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Close stops accepting clients and disconnects the connected ones.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	clients := append([]*client(nil), s.clients...)
+	s.mu.Unlock()
+
+	err := s.ln.Close()
+	for _, c := range clients {
+		s.drop(c, nil)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY creates a pseudo-terminal pair in raw mode and returns its master
+// side and the path of the slave side.
+func openPTY() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to get pty number: %w", err)
+	}
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to unlock pty: %w", err)
+	}
+	path := fmt.Sprintf("/dev/pts/%d", n)
+
+	// Без raw-режима терминал заменял бы \r на \n и возвращал эхо, пока
+	// порт не откроет логгер
+	slave, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	defer slave.Close()
+	var t syscall.Termios
+	if err := ioctl(slave, syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	if err := ioctl(slave, syscall.TCSETS, unsafe.Pointer(&t)); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	return master, path, nil
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+// Package simulator is a synthetic serial device on a pseudo-terminal. The
+// logger opens the slave side of the pty like a real COM port; the
+// simulator writes records of configurable waveforms to the master side,
+// with noise, garbage bytes and disconnects on demand.
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// ErrDisconnected is returned by Write while the device is unplugged.
+var ErrDisconnected = errors.New("simulated device is disconnected")
+
+type Simulator struct {
+	cfg   config.SimulatorConfig
+	start time.Time
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	master  *os.File // nil while disconnected
+	pty     string
+	counter int64
+	closed  bool
+}
+
+// New creates the pseudo-terminal and, if configured, the link to it.
+func New(cfg config.SimulatorConfig) (*Simulator, error) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s := &Simulator{cfg: cfg, start: time.Now(), rnd: rand.New(rand.NewSource(seed))}
+	if err := s.plug(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the path to open the device at: the link if there is one,
+// since the pty changes after every disconnect.
+func (s *Simulator) Path() string {
+	if s.cfg.Link != "" {
+		return s.cfg.Link
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pty
+}
+
+func (s *Simulator) plug() error {
+	master, pty, err := openPTY()
+	if err != nil {
+		return fmt.Errorf("failed to create pseudo-terminal: %w", err)
+	}
+	if s.cfg.Link != "" {
+		// Ссылка, оставшаяся от прошлого запуска, заменяется атомарно
+		tmp := s.cfg.Link + ".tmp"
+		os.Remove(tmp)
+		if err := os.Symlink(pty, tmp); err != nil {
+			master.Close()
+			return fmt.Errorf("failed to link %s: %w", s.cfg.Link, err)
+		}
+		if err := os.Rename(tmp, s.cfg.Link); err != nil {
+			os.Remove(tmp)
+			master.Close()
+			return fmt.Errorf("failed to link %s: %w", s.cfg.Link, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.master, s.pty = master, pty
+	s.mu.Unlock()
+	go s.drain(master)
+	return nil
+}
+
+// drain reads what the logger sends to the device, so that its writes do
+// not block, and logs it.
+func (s *Simulator) drain(master *os.File) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := master.Read(buf)
+		if n > 0 {
+			log.Printf("Simulator received %q", buf[:n])
+		}
+		if errors.Is(err, os.ErrClosed) {
+			return
+		}
+		if err != nil {
+			// EIO, пока порт никем не открыт
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// Run writes a record every interval and unplugs the device on schedule
+// until ctx is cancelled.
+func (s *Simulator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(s.cfg.Interval))
+	defer ticker.Stop()
+
+	var disconnect <-chan time.Time
+	if s.cfg.DisconnectEvery > 0 {
+		t := time.NewTicker(time.Duration(s.cfg.DisconnectEvery))
+		defer t.Stop()
+		disconnect = t.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-disconnect:
+			log.Printf("Simulator: unplugged for %s", s.cfg.Downtime)
+			if err := s.Disconnect(ctx, time.Duration(s.cfg.Downtime)); err != nil {
+				return err
+			}
+			log.Printf("Simulator: plugged in at %s", s.Path())
+		case now := <-ticker.C:
+			// Пока устройство отключено вызовом Disconnect, записи теряются
+			err := s.Write(s.Record(now))
+			if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, ErrDisconnected) {
+				return err
+			}
+		}
+	}
+}
+
+// Record returns the next record at time t, with the line ending, and
+// possibly garbage before it.
+func (s *Simulator) Record(t time.Time) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := t.Sub(s.start)
+	names := make([]string, 0, len(s.cfg.Waves)+1)
+	values := make([]string, 0, len(s.cfg.Waves)+1)
+	if s.cfg.Counter != "" {
+		names = append(names, s.cfg.Counter)
+		values = append(values, strconv.FormatInt(s.counter, 10))
+		s.counter++
+		if s.cfg.CounterModulus > 0 {
+			s.counter %= s.cfg.CounterModulus
+		}
+	}
+	for _, w := range s.cfg.Waves {
+		v := sample(w, elapsed)
+		if s.cfg.Noise > 0 {
+			v += s.rnd.NormFloat64() * s.cfg.Noise
+		}
+		names = append(names, w.Name)
+		values = append(values, strconv.FormatFloat(v, 'f', 3, 64))
+	}
+
+	var b strings.Builder
+	if s.cfg.Garbage > 0 && s.rnd.Float64() < s.cfg.Garbage {
+		for n := 1 + s.rnd.Intn(8); n > 0; n-- {
+			c := byte(s.rnd.Intn(256))
+			if c == '\n' || c == '\r' {
+				c = 0xFF
+			}
+			b.WriteByte(c)
+		}
+	}
+	switch s.cfg.Format {
+	case config.SimulateJSON:
+		b.WriteByte('{')
+		for i := range names {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			name, _ := json.Marshal(names[i])
+			b.Write(name)
+			b.WriteByte(':')
+			b.WriteString(values[i])
+		}
+		b.WriteByte('}')
+	case config.SimulateKV:
+		for i := range names {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(names[i] + "=" + values[i])
+		}
+	default:
+		b.WriteString(strings.Join(values, ","))
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// sample returns the value of the wave after elapsed time.
+func sample(w config.Wave, elapsed time.Duration) float64 {
+	phase := math.Mod(float64(elapsed)/float64(w.Period), 1)
+	var shape float64
+	switch w.Shape {
+	case config.WaveSine:
+		shape = math.Sin(2 * math.Pi * phase)
+	case config.WaveSquare:
+		shape = 1
+		if phase >= 0.5 {
+			shape = -1
+		}
+	case config.WaveTriangle:
+		shape = 1 - 4*math.Abs(phase-0.5)
+	case config.WaveSawtooth:
+		shape = 2*phase - 1
+	}
+	return w.Offset + w.Amplitude*shape
+}
+
+// Write sends raw bytes to whoever has the port open. If nobody reads them
+// for an interval they are dropped with os.ErrDeadlineExceeded.
+func (s *Simulator) Write(p []byte) error {
+	s.mu.Lock()
+	master := s.master
+	s.mu.Unlock()
+	if master == nil {
+		return ErrDisconnected
+	}
+
+	master.SetWriteDeadline(time.Now().Add(time.Duration(s.cfg.Interval)))
+	_, err := master.Write(p)
+	if errors.Is(err, os.ErrClosed) {
+		return ErrDisconnected
+	}
+	return err
+}
+
+// Disconnect unplugs the device: the pty is closed, so a reader gets an
+// error, and after downtime a new one appears at the link.
+func (s *Simulator) Disconnect(ctx context.Context, downtime time.Duration) error {
+	s.unplug()
+
+	timer := time.NewTimer(downtime)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return os.ErrClosed
+	}
+	return s.plug()
+}
+
+func (s *Simulator) unplug() {
+	s.mu.Lock()
+	master := s.master
+	s.master = nil
+	s.mu.Unlock()
+
+	if master != nil {
+		master.Close()
+	}
+	if s.cfg.Link != "" {
+		os.Remove(s.cfg.Link)
+	}
+}
+
+// Close unplugs the device for good and removes the link.
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.unplug()
+	return nil
+}
+
+// Describe returns a summary of the records for the log.
+func (s *Simulator) Describe() string {
+	var names []string
+	if s.cfg.Counter != "" {
+		names = append(names, s.cfg.Counter)
+	}
+	for _, w := range s.cfg.Waves {
+		names = append(names, fmt.Sprintf("%s (%s)", w.Name, w.Shape))
+	}
+	return fmt.Sprintf("%s records of %s every %s", s.cfg.Format, strings.Join(names, ", "), s.cfg.Interval)
+}
@@ -0,0 +1,140 @@
+package simulator_test
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/parser"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/serial"
+	"github.com/physicist2018/gomodserial-v1/internal/infrastructure/simulator"
+	"github.com/physicist2018/gomodserial-v1/pkg/config"
+)
+
+// TestPortListener reads the simulated device through PortListener, unplugs
+// it and checks that the listener reconnects and the records go on.
+func TestPortListener(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the simulator needs a Linux pseudo-terminal")
+	}
+
+	simCfg := config.SimulatorConfig{
+		Link:     filepath.Join(t.TempDir(), "ttySIM"),
+		Interval: config.Duration(20 * time.Millisecond),
+		Waves:    []config.Wave{{Name: "v", Shape: config.WaveConstant, Offset: 1.5}},
+		Counter:  "n",
+		Seed:     1,
+	}
+	if err := simCfg.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	sim, err := simulator.New(simCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	go sim.Run(ctx)
+
+	portCfg := config.PortConfig{
+		Device:   simCfg.Link,
+		BaudRate: 115200,
+		DTR:      true,
+		RTS:      true,
+		Reconnect: config.ReconnectConfig{
+			InitialDelay: config.Duration(50 * time.Millisecond),
+			MaxDelay:     config.Duration(200 * time.Millisecond),
+		},
+	}
+	if err := portCfg.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := parser.New(config.ParserConfig{Type: config.ParserCSV}, []entity.Channel{{Name: "n"}, {Name: "v"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := make(chan serial.State, 64)
+	pl := serial.NewPortListener(portCfg)
+	pl.OnStateChange(func(c serial.StateChange) {
+		select {
+		case states <- c.State:
+		default:
+		}
+	})
+	dataChan := make(chan serial.Frame)
+	errorChan := make(chan error, 1)
+	go pl.Listen(ctx, dataChan, errorChan)
+	defer pl.Close()
+
+	// next returns the counter of the next record, checking its values.
+	next := func() int64 {
+		t.Helper()
+		select {
+		case f := <-dataChan:
+			values, err := p.Parse(f.Data)
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", f.Data, err)
+			}
+			if len(values) != 2 || values[1].Value != 1.5 {
+				t.Fatalf("unexpected values %v in %q", values, f.Data)
+			}
+			if f.ReceivedAt.IsZero() {
+				t.Fatalf("frame %q has no receive time", f.Data)
+			}
+			return int64(values[0].Value)
+		case err := <-errorChan:
+			t.Fatalf("listening failed: %v", err)
+		case <-ctx.Done():
+			t.Fatal("no data from the simulator")
+		}
+		return 0
+	}
+	// Первая запись может прийти обрезанной, если порт открылся посреди неё
+	next()
+	last := next()
+	for i := 0; i < 5; i++ {
+		n := next()
+		if n != last+1 {
+			t.Fatalf("record %d follows %d", n, last)
+		}
+		last = n
+	}
+
+	go sim.Disconnect(ctx, 300*time.Millisecond)
+	var reconnecting bool
+	for !reconnecting {
+		select {
+		case s := <-states:
+			reconnecting = s == serial.StateReconnecting
+		case <-dataChan:
+			// записи, прочитанные до отключения
+		case <-ctx.Done():
+			t.Fatal("the listener did not notice the disconnect")
+		}
+	}
+	for connected := false; !connected; {
+		select {
+		case s := <-states:
+			connected = s == serial.StateConnected
+		case err := <-errorChan:
+			t.Fatalf("listening failed: %v", err)
+		case <-ctx.Done():
+			t.Fatal("the listener did not reconnect")
+		}
+	}
+
+	next()
+	after := next()
+	if after <= last {
+		t.Fatalf("counter went back from %d to %d after reconnecting", last, after)
+	}
+	if got := pl.StateInfo().State; got != serial.StateConnected {
+		t.Fatalf("state is %s after reconnecting", got)
+	}
+}
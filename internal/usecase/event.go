@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+)
+
+type EventUseCase struct {
+	eventRepo entity.EventRepository
+}
+
+func NewEventUseCase(repo entity.EventRepository) *EventUseCase {
+	return &EventUseCase{eventRepo: repo}
+}
+
+func (uc *EventUseCase) RecordEvent(ctx context.Context, event *entity.Event) error {
+	if event.StartedAt.IsZero() {
+		event.StartedAt = time.Now()
+	}
+	if event.EndedAt.IsZero() {
+		event.EndedAt = event.StartedAt
+	}
+	return uc.eventRepo.CreateEvent(ctx, event)
+}
+
+func (uc *EventUseCase) GetEventsByExperimentID(ctx context.Context, experimentID int) ([]entity.Event, error) {
+	return uc.eventRepo.GetEventsByExperimentID(ctx, experimentID)
+}
@@ -2,12 +2,18 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/physicist2018/gomodserial-v1/internal/domain"
 	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
 )
 
+// ErrInvalidInput marks errors caused by invalid user input.
+var ErrInvalidInput = errors.New("invalid input")
+
 type ExperimentUseCase struct {
 	experimentRepository entity.ExperimentRepository
 }
@@ -18,11 +24,21 @@ func NewExperimentUseCase(experimentRepository entity.ExperimentRepository) *Exp
 	}
 }
 
-func (uc *ExperimentUseCase) CreateExperiment(ctx context.Context, name, description string) (*entity.Experiment, error) {
+// CreateExperiment stores a new experiment. Name, description, port,
+// channels and parser are taken from draft; the rest is filled in here.
+func (uc *ExperimentUseCase) CreateExperiment(ctx context.Context, draft entity.Experiment) (*entity.Experiment, error) {
+	channels, err := normalizeChannels(draft.Channels)
+	if err != nil {
+		return nil, err
+	}
+
 	experiment := &entity.Experiment{
-		Name:        name,
-		Description: description,
+		Name:        draft.Name,
+		Description: draft.Description,
 		CreatedAt:   time.Now(),
+		Port:        draft.Port,
+		Channels:    channels,
+		Parser:      draft.Parser,
 	}
 
 	id, err := uc.experimentRepository.CreateExperiment(ctx, experiment)
@@ -32,9 +48,38 @@ func (uc *ExperimentUseCase) CreateExperiment(ctx context.Context, name, descrip
 	}
 
 	experiment.ID = id
+	for i := range experiment.Channels {
+		experiment.Channels[i].ExperimentID = id
+	}
 	return experiment, nil
 }
 
+func normalizeChannels(channels []entity.Channel) ([]entity.Channel, error) {
+	names := make(map[string]bool)
+	result := make([]entity.Channel, 0, len(channels))
+	for _, ch := range channels {
+		ch.Name = strings.TrimSpace(ch.Name)
+		ch.Unit = strings.TrimSpace(ch.Unit)
+		if ch.Name == "" {
+			return nil, fmt.Errorf("%w: channel name is empty", ErrInvalidInput)
+		}
+		if names[ch.Name] {
+			return nil, fmt.Errorf("%w: duplicate channel %q", ErrInvalidInput, ch.Name)
+		}
+		names[ch.Name] = true
+
+		if ch.Type == "" {
+			ch.Type = entity.ChannelNumeric
+		}
+		if !ch.Type.Valid() {
+			return nil, fmt.Errorf("%w: channel %q has unknown type %q", ErrInvalidInput, ch.Name, ch.Type)
+		}
+		ch.Position = len(result)
+		result = append(result, ch)
+	}
+	return result, nil
+}
+
 func (uc *ExperimentUseCase) GetAllExperiments(ctx context.Context) ([]entity.Experiment, error) {
 	return uc.experimentRepository.GetAllExperiments(ctx)
 }
@@ -42,3 +87,27 @@ func (uc *ExperimentUseCase) GetAllExperiments(ctx context.Context) ([]entity.Ex
 func (uc *ExperimentUseCase) GetExperimentByID(ctx context.Context, id int) (*entity.Experiment, error) {
 	return uc.experimentRepository.GetExperimentByID(ctx, id)
 }
+
+// SetInstrument records the identity of the instrument that feeds the
+// experiment.
+func (uc *ExperimentUseCase) SetInstrument(ctx context.Context, id int, instrument string) error {
+	return uc.experimentRepository.SetExperimentInstrument(ctx, id, instrument)
+}
+
+// DeleteExperiment removes an experiment with all its data.
+func (uc *ExperimentUseCase) DeleteExperiment(ctx context.Context, id int) error {
+	return uc.experimentRepository.DeleteExperiment(ctx, id)
+}
+
+// SaveStats records the summary of a data collection session.
+func (uc *ExperimentUseCase) SaveStats(ctx context.Context, stats *entity.SessionStats) error {
+	return uc.experimentRepository.SaveSessionStats(ctx, stats)
+}
+
+func (uc *ExperimentUseCase) GetStats(ctx context.Context, experimentID int) ([]entity.SessionStats, error) {
+	return uc.experimentRepository.GetSessionStats(ctx, experimentID)
+}
+
+func (uc *ExperimentUseCase) GetChannels(ctx context.Context, experimentID int) ([]entity.Channel, error) {
+	return uc.experimentRepository.GetChannelsByExperimentID(ctx, experimentID)
+}
@@ -15,11 +15,9 @@ func NewMeasurementUseCase(repo entity.MeasurementRepository) *MeasurementUseCas
 	return &MeasurementUseCase{measurementRepo: repo}
 }
 
-func (uc *MeasurementUseCase) CreateMeasurement(ctx context.Context, experimentID int, value string) error {
-	measurement := &entity.Measurement{
-		ExperimentID: experimentID,
-		Value:        value,
-		Timestamp:    time.Now(),
+func (uc *MeasurementUseCase) CreateMeasurement(ctx context.Context, measurement *entity.Measurement) error {
+	if measurement.Timestamp.IsZero() {
+		measurement.Timestamp = time.Now()
 	}
 
 	err := uc.measurementRepo.CreateMeasurement(ctx, measurement)
@@ -30,3 +28,16 @@ func (uc *MeasurementUseCase) CreateMeasurement(ctx context.Context, experimentI
 func (uc *MeasurementUseCase) GetMeasurementsByExperimentID(ctx context.Context, experimentID int) ([]entity.Measurement, error) {
 	return uc.measurementRepo.GetMeasurementsByExperimentID(ctx, experimentID)
 }
+
+// QuarantineFrame stores a frame that failed its integrity check instead of
+// a measurement.
+func (uc *MeasurementUseCase) QuarantineFrame(ctx context.Context, frame *entity.QuarantinedFrame) error {
+	if frame.ReceivedAt.IsZero() {
+		frame.ReceivedAt = time.Now()
+	}
+	return uc.measurementRepo.QuarantineFrame(ctx, frame)
+}
+
+func (uc *MeasurementUseCase) GetQuarantinedFrames(ctx context.Context, experimentID int) ([]entity.QuarantinedFrame, error) {
+	return uc.measurementRepo.GetQuarantinedFrames(ctx, experimentID)
+}
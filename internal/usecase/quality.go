@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+)
+
+// QualityReport summarizes the record counter of an experiment: how many
+// records arrived, how many the counter shows as lost, and every break in
+// the sequence with its time range.
+type QualityReport struct {
+	Records    int                    `json:"records"`      // measurements with a sequence number
+	Missing    int64                  `json:"missing"`      // records lost in gaps and not received late
+	Duplicates int                    `json:"duplicates"`   // records received more than once
+	OutOfOrder int                    `json:"out_of_order"` // records that arrived after a later one
+	Resets     int                    `json:"resets"`       // times the counter started over
+	From       time.Time              `json:"from"`
+	To         time.Time              `json:"to"`
+	Issues     []entity.SequenceIssue `json:"issues"`
+}
+
+// Completeness is the share of the expected records that arrived, in
+// percent.
+func (r QualityReport) Completeness() float64 {
+	received := int64(r.Records - r.Duplicates)
+	if received+r.Missing == 0 {
+		return 100
+	}
+	return 100 * float64(received) / float64(received+r.Missing)
+}
+
+// QualityReport checks the sequence numbers of the stored measurements in
+// the order they were received. modulus is the wrap of the counter, see
+// entity.SequenceTracker.
+func (uc *MeasurementUseCase) QualityReport(ctx context.Context, experimentID int, modulus int64) (*QualityReport, error) {
+	measurements, err := uc.measurementRepo.GetMeasurementsByExperimentID(ctx, experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QualityReport{Issues: []entity.SequenceIssue{}}
+	tracker := &entity.SequenceTracker{Modulus: modulus}
+	for _, m := range measurements {
+		if m.Sequence == nil {
+			continue
+		}
+		if report.Records == 0 {
+			report.From = m.Timestamp
+		}
+		report.To = m.Timestamp
+		report.Records++
+
+		issue := tracker.Next(*m.Sequence, m.Timestamp)
+		if issue == nil {
+			continue
+		}
+		switch issue.Kind {
+		case entity.EventSequenceGap:
+			report.Missing += issue.Missing
+		case entity.EventDuplicate:
+			report.Duplicates++
+		case entity.EventOutOfOrder:
+			report.OutOfOrder++
+			// Потерянной запись была, только если попала в пропуск
+			if issue.Filled {
+				report.Missing--
+			}
+		case entity.EventCounterReset:
+			// Найденные до сброса пропуски остаются в отчёте
+			report.Resets++
+		}
+		report.Issues = append(report.Issues, *issue)
+	}
+	return report, nil
+}
@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/gomodserial-v1/internal/domain/entity"
+)
+
+// measurementStore returns the measurements it holds for any experiment.
+type measurementStore struct {
+	entity.MeasurementRepository
+	measurements []entity.Measurement
+}
+
+func (s *measurementStore) GetMeasurementsByExperimentID(ctx context.Context, experimentID int) ([]entity.Measurement, error) {
+	return s.measurements, nil
+}
+
+func TestQualityReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		modulus int64
+		seqs    []int64
+		want    QualityReport
+	}{
+		{
+			name: "reordered burst",
+			seqs: []int64{1, 2, 6, 3, 4, 5, 7, 8},
+			want: QualityReport{Records: 8, Missing: 0, OutOfOrder: 3},
+		},
+		{
+			name: "gap and duplicate",
+			seqs: []int64{1, 2, 5, 5, 6},
+			want: QualityReport{Records: 5, Missing: 2, Duplicates: 1},
+		},
+		{
+			name: "reset keeps the gaps found before it",
+			seqs: []int64{5000, 5003, 5004, 1, 2, 4},
+			want: QualityReport{Records: 6, Missing: 3, Resets: 1},
+		},
+		{
+			name:    "wrap",
+			modulus: 256,
+			seqs:    []int64{254, 255, 0, 2, 1},
+			want:    QualityReport{Records: 5, Missing: 0, OutOfOrder: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			store := &measurementStore{}
+			for i, seq := range tt.seqs {
+				seq := seq
+				store.measurements = append(store.measurements, entity.Measurement{
+					Timestamp: start.Add(time.Duration(i) * time.Second),
+					Sequence:  &seq,
+				})
+			}
+			// Записи без номера в отчёт не входят
+			store.measurements = append(store.measurements, entity.Measurement{Timestamp: start.Add(time.Hour)})
+
+			report, err := NewMeasurementUseCase(store).QualityReport(context.Background(), 1, tt.modulus)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := *report
+			got.Issues = nil
+			want := tt.want
+			want.From = start
+			want.To = start.Add(time.Duration(len(tt.seqs)-1) * time.Second)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
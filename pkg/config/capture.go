@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// CaptureConfig makes the port save every byte it reads, with the time it
+// was read, to capture files in Dir, one series per experiment. A file is
+// rotated when it reaches MaxSize bytes or is older than MaxAge, and
+// rotated files are gzipped unless Compress is false.
+type CaptureConfig struct {
+	Dir      string   `json:"dir"`
+	MaxSize  int64    `json:"max_size"`
+	MaxAge   Duration `json:"max_age"` // 0 means no time-based rotation
+	Compress *bool    `json:"compress"`
+}
+
+const defaultCaptureMaxSize = 64 << 20
+
+func (cc CaptureConfig) Enabled() bool {
+	return cc.Dir != ""
+}
+
+// Compresses reports whether rotated files are gzipped.
+func (cc CaptureConfig) Compresses() bool {
+	return cc.Compress == nil || *cc.Compress
+}
+
+func (cc *CaptureConfig) Normalize() error {
+	if !cc.Enabled() {
+		return nil
+	}
+	if cc.MaxSize < 0 || cc.MaxAge < 0 {
+		return fmt.Errorf("maximum size and age must not be negative")
+	}
+	if cc.MaxSize == 0 {
+		cc.MaxSize = defaultCaptureMaxSize
+	}
+	if cc.MaxAge > 0 && cc.MaxAge < Duration(time.Second) {
+		return fmt.Errorf("maximum age %s is too short", cc.MaxAge)
+	}
+	return nil
+}
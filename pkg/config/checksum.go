@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChecksumConfig makes the port verify a checksum at the end of every
+// frame, written in hex after Separator: "payload*1F". The checksum covers
+// the payload without its first Skip bytes; the nmea type covers the
+// sentence between "$" and "*".
+type ChecksumConfig struct {
+	Type      string `json:"type"` // nmea, xor, sum, crc8, crc16-ccitt, crc32
+	Separator string `json:"separator"`
+	Skip      int    `json:"skip"`
+}
+
+const (
+	ChecksumNMEA       = "nmea"
+	ChecksumXOR        = "xor"
+	ChecksumSum        = "sum"
+	ChecksumCRC8       = "crc8"
+	ChecksumCRC16CCITT = "crc16-ccitt"
+	ChecksumCRC32      = "crc32"
+)
+
+func (cc ChecksumConfig) Enabled() bool {
+	return cc.Type != ""
+}
+
+func (cc *ChecksumConfig) Normalize() error {
+	cc.Type = strings.ToLower(cc.Type)
+	switch cc.Type {
+	case "":
+		return nil
+	case ChecksumNMEA:
+		cc.Separator = "*"
+		cc.Skip = 1
+	case ChecksumXOR, ChecksumSum, ChecksumCRC8, ChecksumCRC16CCITT, ChecksumCRC32:
+	case "crc16":
+		cc.Type = ChecksumCRC16CCITT
+	default:
+		return fmt.Errorf("unknown checksum type %q. Must be one of nmea, xor, sum, crc8, crc16-ccitt, crc32", cc.Type)
+	}
+	if cc.Separator == "" {
+		cc.Separator = "*"
+	}
+	if cc.Skip < 0 {
+		return fmt.Errorf("invalid number of skipped bytes %d", cc.Skip)
+	}
+	return nil
+}
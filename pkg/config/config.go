@@ -1,28 +1,268 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type AppConfig struct {
-	PortName   string
-	DBName     string
-	ServerPort int
+	DBName          string
+	ServerPort      int
+	Ports           []PortConfig
+	HotplugInterval time.Duration
+}
+
+// PortConfig describes the serial line settings of a single port. Name
+// identifies the port in the web interface and API.
+type PortConfig struct {
+	Name        string      `json:"name"`
+	Source      string      `json:"source"` // kind of data source, serial by default
+	Device      string      `json:"device"`
+	Match       DeviceMatch `json:"match"` // find a USB adapter by its IDs instead of by path
+	BaudRate    int         `json:"baud_rate"`
+	DataBits    int         `json:"data_bits"`
+	Parity      string      `json:"parity"`       // none, odd, even, mark, space
+	StopBits    string      `json:"stop_bits"`    // 1, 1.5, 2
+	FlowControl string      `json:"flow_control"` // none, rtscts, xonxoff
+	DTR         bool        `json:"dtr"`
+	RTS         bool        `json:"rts"`
+
+	CommandEnding string           `json:"command_ending"` // appended to commands sent to the device
+	Encoding      string           `json:"encoding"`       // of the text the device sends; utf-8, cp1251, cp866, latin1
+	TimeSource    string           `json:"time_source"`    // name of a GPS port whose time stamps the measurements
+	DeviceTime    DeviceTimeConfig `json:"device_time"`
+	Sequence      SequenceConfig   `json:"sequence"`
+
+	Framing   FramingConfig   `json:"framing"`
+	Parser    ParserConfig    `json:"parser"`
+	Checksum  ChecksumConfig  `json:"checksum"`
+	Capture   CaptureConfig   `json:"capture"`
+	Replay    ReplayConfig    `json:"replay"`
+	Share     ShareConfig     `json:"share"`
+	Reconnect ReconnectConfig `json:"reconnect"`
+	Watchdog  WatchdogConfig  `json:"watchdog"`
+	Poll      PollConfig      `json:"poll"`
+	Modbus    ModbusConfig    `json:"modbus"`
+	SCPI      SCPIConfig      `json:"scpi"`
+}
+
+// PollConfig switches the port to query/response acquisition: Query (with
+// the command ending) is sent every Interval and the device must answer
+// within Timeout. An empty Query means the device sends data on its own.
+type PollConfig struct {
+	Query    string   `json:"query"`
+	Interval Duration `json:"interval"`
+	Timeout  Duration `json:"timeout"`
+}
+
+func (pc PollConfig) Enabled() bool {
+	return pc.Query != ""
+}
+
+// DeviceTimeConfig reads the time the device gives a measurement from the
+// parsed channel Channel. Format is unix (seconds, the default), unix_ms,
+// rfc3339 or a Go time layout; a layout without a date takes the date the
+// frame was received.
+type DeviceTimeConfig struct {
+	Channel string `json:"channel"`
+	Format  string `json:"format"`
+}
+
+const (
+	DeviceTimeUnix    = "unix"
+	DeviceTimeUnixMs  = "unix_ms"
+	DeviceTimeRFC3339 = "rfc3339"
+)
+
+func (dc DeviceTimeConfig) Enabled() bool {
+	return dc.Channel != ""
+}
+
+func (dc *DeviceTimeConfig) Normalize() error {
+	if !dc.Enabled() {
+		if dc.Format != "" {
+			return fmt.Errorf("format %q given without a channel", dc.Format)
+		}
+		return nil
+	}
+	if dc.Format == "" {
+		dc.Format = DeviceTimeUnix
+	}
+	return nil
+}
+
+// SequenceConfig names the channel that holds the rolling record counter
+// of the device. A counter with a Modulus wraps to 0 after Modulus-1, e.g.
+// 65536 for a 16-bit counter; 0 means it never wraps.
+type SequenceConfig struct {
+	Channel string `json:"channel"`
+	Modulus int64  `json:"modulus"`
+}
+
+func (sc SequenceConfig) Enabled() bool {
+	return sc.Channel != ""
+}
+
+// ReconnectConfig limits how a lost port is reopened. The delay between
+// attempts starts at InitialDelay and grows by Multiplier up to MaxDelay;
+// each delay is randomized by ±Jitter (a fraction of the delay). After
+// MaxAttempts failed attempts in a row the port gives up; 0 means never.
+type ReconnectConfig struct {
+	InitialDelay Duration `json:"initial_delay"`
+	MaxDelay     Duration `json:"max_delay"`
+	Multiplier   float64  `json:"multiplier"`
+	Jitter       float64  `json:"jitter"`
+	MaxAttempts  int      `json:"max_attempts"`
+}
+
+// DeviceMatch identifies a USB serial adapter independently of the path
+// the system assigns to it.
+type DeviceMatch struct {
+	VID          string `json:"vid"`
+	PID          string `json:"pid"`
+	SerialNumber string `json:"serial_number"`
+}
+
+func (m DeviceMatch) IsEmpty() bool {
+	return m.VID == "" && m.PID == "" && m.SerialNumber == ""
+}
+
+func (m DeviceMatch) String() string {
+	s := m.VID + ":" + m.PID
+	if m.SerialNumber != "" {
+		s += " S/N " + m.SerialNumber
+	}
+	return s
+}
+
+// ParseDeviceMatch parses the command line form VID:PID[:SERIAL].
+func ParseDeviceMatch(spec string) (DeviceMatch, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return DeviceMatch{}, fmt.Errorf("expected VID:PID[:SERIAL], got %q", spec)
+	}
+	m := DeviceMatch{VID: parts[0], PID: parts[1]}
+	if len(parts) == 3 {
+		m.SerialNumber = parts[2]
+	}
+	return m, nil
+}
+
+// FramingConfig selects how the byte stream is split into frames.
+type FramingConfig struct {
+	Type      string `json:"type"`       // line, delimiter, fixed, stxetx, slip, cobs
+	Delimiter string `json:"delimiter"`  // for "delimiter"
+	Length    int    `json:"length"`     // for "fixed"
+	MaxLength int    `json:"max_length"` // longer frames are discarded as overruns
+}
+
+// ParserConfig selects how frames are split into channel values.
+type ParserConfig struct {
+	Type      string        `json:"type"`      // auto, raw, delimited, csv, kv, json, regex, binary, nmea
+	Separator string        `json:"separator"` // field separator for delimited and csv
+	Pattern   string        `json:"pattern"`   // regular expression with named groups for regex
+	Layout    []LayoutField `json:"layout"`    // record layout for binary
+}
+
+const (
+	ParityNone  = "none"
+	ParityOdd   = "odd"
+	ParityEven  = "even"
+	ParityMark  = "mark"
+	ParitySpace = "space"
+
+	FlowNone    = "none"
+	FlowRTSCTS  = "rtscts"
+	FlowXONXOFF = "xonxoff"
+
+	FramingLine      = "line"
+	FramingDelimiter = "delimiter"
+	FramingFixed     = "fixed"
+	FramingSTXETX    = "stxetx"
+	FramingSLIP      = "slip"
+	FramingCOBS      = "cobs"
+	FramingModbus    = "modbus"
+
+	defaultMaxFrameLength = 4096
+
+	ParserAuto      = "auto"
+	ParserRaw       = "raw"
+	ParserDelimited = "delimited"
+	ParserCSV       = "csv"
+	ParserKeyValue  = "kv"
+	ParserJSON      = "json"
+	ParserRegex     = "regex"
+	ParserBinary    = "binary"
+	ParserNMEA      = "nmea"
+)
+
+// fileConfig is the layout of the file given with -config.
+type fileConfig struct {
+	Ports []PortConfig `json:"ports"`
 }
 
 func Load() (*AppConfig, error) {
 	cfg := &AppConfig{}
+	port := PortConfig{}
+	port.Reconnect = DefaultPortConfig().Reconnect
 
 	// Установка значений по умолчанию
 	defaultDBPath := filepath.Join("data", "experiments.db")
 
 	// Парсинг флагов
 	flag.StringVar(&cfg.DBName, "db", defaultDBPath, "SQLite database file path")
-	flag.StringVar(&cfg.PortName, "com", "/dev/ttyUSB0", "COM port name")
+	flag.StringVar(&port.Source, "source", SourceSerial, "Kind of data source the port is read from")
+	flag.StringVar(&port.Device, "com", "/dev/ttyUSB0", "COM port name, or tcp://host:port or rfc2217://host:port for a serial-to-Ethernet converter")
+	match := flag.String("match", "", "Find the COM port by USB VID:PID[:SERIAL] instead of by name")
 	flag.IntVar(&cfg.ServerPort, "port", 5000, "Server port number")
+	flag.DurationVar(&cfg.HotplugInterval, "hotplug-interval", 2*time.Second, "How often to look for attached serial devices, 0 disables")
+	configFile := flag.String("config", "", "JSON file with the settings of one or more ports; replaces the COM port flags")
+	flag.IntVar(&port.BaudRate, "baud", 9600, "COM port baud rate")
+	flag.IntVar(&port.DataBits, "databits", 8, "COM port data bits (5, 6, 7 or 8)")
+	flag.StringVar(&port.Parity, "parity", ParityNone, "COM port parity (none, odd, even, mark, space)")
+	flag.StringVar(&port.StopBits, "stopbits", "1", "COM port stop bits (1, 1.5, 2)")
+	flag.StringVar(&port.FlowControl, "flow", FlowNone, "COM port flow control (none, rtscts, xonxoff)")
+	flag.BoolVar(&port.DTR, "dtr", true, "Assert DTR after opening the COM port")
+	flag.BoolVar(&port.RTS, "rts", true, "Assert RTS after opening the COM port")
+	flag.DurationVar((*time.Duration)(&port.Reconnect.InitialDelay), "reconnect-delay", time.Second, "Delay before the first attempt to reopen a lost COM port")
+	flag.DurationVar((*time.Duration)(&port.Reconnect.MaxDelay), "reconnect-max-delay", time.Minute, "Longest delay between attempts to reopen a lost COM port")
+	flag.IntVar(&port.Reconnect.MaxAttempts, "reconnect-attempts", 0, "Give up after this many failed attempts to reopen the COM port, 0 means never")
+	flag.StringVar(&port.Framing.Type, "framing", FramingLine, "Frame format (line, delimiter, fixed, stxetx, slip, cobs)")
+	flag.StringVar(&port.Poll.Query, "poll-query", "", "Poll the device with this query instead of waiting for data, e.g. \"MEAS?\"")
+	flag.DurationVar((*time.Duration)(&port.Poll.Interval), "poll-interval", time.Second, "Interval between queries for -poll-query")
+	flag.DurationVar((*time.Duration)(&port.Poll.Timeout), "poll-timeout", 0, "How long to wait for the response to -poll-query (default: the interval)")
+	flag.StringVar(&port.DeviceTime.Channel, "device-time", "", "Channel that holds the device's own timestamp of a measurement")
+	flag.StringVar(&port.DeviceTime.Format, "device-time-format", "", "Format of -device-time: unix, unix_ms, rfc3339 or a Go time layout (default unix)")
+	flag.StringVar(&port.Sequence.Channel, "sequence", "", "Channel that holds the rolling record counter of the device")
+	flag.Int64Var(&port.Sequence.Modulus, "sequence-modulus", 0, "The counter of -sequence wraps to 0 at this value, e.g. 65536; 0 means it never wraps")
+	flag.StringVar(&port.Capture.Dir, "capture-dir", "", "Save every byte read from the COM port to capture files in this directory")
+	flag.Int64Var(&port.Capture.MaxSize, "capture-max-size", defaultCaptureMaxSize, "Rotate a capture file when it reaches this many bytes")
+	flag.DurationVar((*time.Duration)(&port.Capture.MaxAge), "capture-max-age", 0, "Rotate a capture file when it gets older than this, 0 disables")
+	flag.StringVar(&port.Share.Listen, "share", "", "Serve the bytes read from the COM port to TCP clients on this address, e.g. :4100")
+	flag.StringVar(&port.Share.Mode, "share-mode", ShareReadOnly, "What -share clients may do (read-only, exclusive: the first client may write to the device)")
+	flag.DurationVar((*time.Duration)(&port.Watchdog.Timeout), "watchdog", 0, "Alert when the open COM port gets no data for this long, 0 disables")
+	flag.BoolVar(&port.Watchdog.PulseDTR, "watchdog-dtr", false, "Pulse DTR to reset the device when -watchdog fires")
+	flag.StringVar(&port.Watchdog.WakeCommand, "watchdog-command", "", "Send this command to the device when -watchdog fires")
+	flag.StringVar(&port.Replay.File, "replay", "", "Feed the port from capture files (a file or glob pattern) instead of the COM port")
+	flag.Float64Var(&port.Replay.Speed, "replay-speed", 1, "Replay speed relative to the original timing")
+	flag.BoolVar(&port.Replay.Fast, "replay-fast", false, "Replay as fast as possible, ignoring the original timing")
+	commandEnding := flag.String("command-ending", `\r\n`, "Appended to commands sent to the device, Go escapes allowed")
+	delimiter := flag.String("delimiter", "", `Frame delimiter for -framing delimiter, Go escapes allowed (e.g. "\r" or "\x03")`)
+	flag.IntVar(&port.Framing.Length, "frame-length", 0, "Record size in bytes for -framing fixed")
+	flag.IntVar(&port.Framing.MaxLength, "max-frame", defaultMaxFrameLength, "Maximum frame size in bytes, longer frames are dropped")
+	layout := flag.String("layout", "", "Binary record layout, e.g. \"counter:uint16,temp:int32:le:0.01,pressure:float32:be\"")
+	flag.StringVar(&port.Parser.Type, "parser", ParserAuto, "Line parser (auto, raw, delimited, csv, kv, json, regex, binary, nmea)")
+	flag.StringVar(&port.Parser.Separator, "separator", "", "Field separator for the delimited and csv parsers")
+	flag.StringVar(&port.Parser.Pattern, "pattern", "", "Regular expression with named groups for the regex parser")
+	flag.StringVar(&port.Encoding, "encoding", "", "Character encoding of the text the device sends (utf-8, cp1251, cp866, latin1); undecodable bytes are kept as \\xNN")
+	flag.StringVar(&port.Checksum.Type, "checksum", "", "Verify the checksum at the end of every line (nmea, xor, sum, crc8, crc16-ccitt, crc32)")
 
 	// Кастомное сообщение при использовании -h
 	flag.Usage = func() {
@@ -38,6 +278,63 @@ func Load() (*AppConfig, error) {
 		return nil, fmt.Errorf("invalid port number %d. Must be between 1 and 65535", cfg.ServerPort)
 	}
 
+	if *delimiter != "" {
+		unquoted, err := strconv.Unquote(`"` + *delimiter + `"`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame delimiter %q: %w", *delimiter, err)
+		}
+		port.Framing.Delimiter = unquoted
+	}
+
+	ending, err := strconv.Unquote(`"` + *commandEnding + `"`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command ending %q: %w", *commandEnding, err)
+	}
+	port.CommandEnding = ending
+
+	if *layout != "" {
+		fields, err := ParseLayout(*layout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary layout: %w", err)
+		}
+		port.Parser.Layout = fields
+	}
+
+	comSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "com" {
+			comSet = true
+		}
+	})
+	if *match != "" {
+		m, err := ParseDeviceMatch(*match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device match: %w", err)
+		}
+		port.Match = m
+		// Без явного -com порт ищется только по USB-идентификаторам
+		if !comSet {
+			port.Device = ""
+		}
+	}
+	if port.Replay.Enabled() && !comSet {
+		port.Device = ""
+	}
+
+	if *configFile != "" {
+		ports, err := loadPortsFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Ports = ports
+	} else {
+		cfg.Ports = []PortConfig{port}
+	}
+
+	if err := normalizePorts(cfg.Ports); err != nil {
+		return nil, err
+	}
+
 	// Создаем директорию для БД если не существует
 	if err := os.MkdirAll(filepath.Dir(cfg.DBName), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
@@ -45,3 +342,423 @@ func Load() (*AppConfig, error) {
 
 	return cfg, nil
 }
+
+// DefaultPortConfig returns the settings used for everything a port
+// configuration file leaves out.
+func DefaultPortConfig() PortConfig {
+	return PortConfig{
+		BaudRate:    9600,
+		DataBits:    8,
+		Parity:      ParityNone,
+		StopBits:    "1",
+		FlowControl: FlowNone,
+		DTR:         true,
+		RTS:         true,
+		Framing:     FramingConfig{Type: FramingLine, MaxLength: defaultMaxFrameLength},
+		Parser:      ParserConfig{Type: ParserAuto},
+
+		CommandEnding: "\r\n",
+		Reconnect: ReconnectConfig{
+			InitialDelay: Duration(time.Second),
+			MaxDelay:     Duration(time.Minute),
+			Multiplier:   2,
+			Jitter:       0.2,
+		},
+	}
+}
+
+func (pc *PortConfig) UnmarshalJSON(data []byte) error {
+	type plain PortConfig
+	p := plain(DefaultPortConfig())
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*pc = PortConfig(p)
+	return nil
+}
+
+func loadPortsFile(path string) ([]PortConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(fc.Ports) == 0 {
+		return nil, fmt.Errorf("config file %s defines no ports", path)
+	}
+	return fc.Ports, nil
+}
+
+// normalizePorts validates every port and makes sure names and devices
+// are not used twice.
+func normalizePorts(ports []PortConfig) error {
+	names := make(map[string]bool)
+	devices := make(map[string]string)
+	shares := make(map[string]string)
+	for i := range ports {
+		pc := &ports[i]
+		if err := pc.Normalize(); err != nil {
+			if pc.Name != "" {
+				return fmt.Errorf("invalid settings of port %q: %w", pc.Name, err)
+			}
+			return fmt.Errorf("invalid settings of port #%d: %w", i+1, err)
+		}
+		if names[pc.Name] {
+			return fmt.Errorf("port name %q is used more than once", pc.Name)
+		}
+		names[pc.Name] = true
+		if pc.Share.Enabled() {
+			if other, ok := shares[pc.Share.Listen]; ok {
+				return fmt.Errorf("ports %q and %q are both shared on %s", other, pc.Name, pc.Share.Listen)
+			}
+			shares[pc.Share.Listen] = pc.Name
+		}
+		if pc.Device == "" {
+			continue
+		}
+		if other, ok := devices[pc.Device]; ok {
+			return fmt.Errorf("ports %q and %q both use device %s", other, pc.Name, pc.Device)
+		}
+		devices[pc.Device] = pc.Name
+	}
+
+	for _, pc := range ports {
+		if pc.TimeSource == "" {
+			continue
+		}
+		source := findPort(ports, pc.TimeSource)
+		switch {
+		case source == nil:
+			return fmt.Errorf("port %q: unknown time source port %q", pc.Name, pc.TimeSource)
+		case source.Name == pc.Name:
+			return fmt.Errorf("port %q cannot be its own time source", pc.Name)
+		case source.Parser.Type != ParserNMEA:
+			return fmt.Errorf("port %q: time source %q must use the nmea parser", pc.Name, pc.TimeSource)
+		}
+	}
+	return nil
+}
+
+func findPort(ports []PortConfig, name string) *PortConfig {
+	for i := range ports {
+		if ports[i].Name == name {
+			return &ports[i]
+		}
+	}
+	return nil
+}
+
+// Normalize fills in defaults, converts short spellings ("E", "hw") to their
+// canonical form and validates the result.
+func (pc *PortConfig) Normalize() error {
+	pc.Source = strings.ToLower(pc.Source)
+	if pc.Source == "" {
+		pc.Source = SourceSerial
+	}
+	if err := pc.normalizeNetwork(); err != nil {
+		return err
+	}
+	if err := pc.Replay.Normalize(); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	if pc.Replay.Enabled() {
+		if pc.Device != "" || !pc.Match.IsEmpty() {
+			return fmt.Errorf("a replayed port must not have a device name or USB match")
+		}
+		if pc.Source != SourceSerial {
+			return fmt.Errorf("only serial ports can be replayed")
+		}
+	} else if pc.Device == "" && pc.Match.IsEmpty() {
+		return fmt.Errorf("neither device name nor USB match is set")
+	}
+
+	if pc.Name == "" {
+		if pc.Replay.Enabled() {
+			pc.Name = defaultPortName(replayPortName(pc.Replay.File))
+		} else if pc.Device != "" {
+			pc.Name = defaultPortName(pc.Device)
+		} else {
+			pc.Name = defaultPortName("usb-" + pc.Match.VID + "-" + pc.Match.PID)
+		}
+	}
+	if !validPortName(pc.Name) {
+		return fmt.Errorf("invalid port name %q. Only letters, digits, '.', '_' and '-' are allowed", pc.Name)
+	}
+
+	if pc.BaudRate <= 0 {
+		return fmt.Errorf("invalid baud rate %d. Must be a positive number", pc.BaudRate)
+	}
+
+	if pc.DataBits == 0 {
+		pc.DataBits = 8
+	}
+	if pc.DataBits < 5 || pc.DataBits > 8 {
+		return fmt.Errorf("invalid data bits %d. Must be 5, 6, 7 or 8", pc.DataBits)
+	}
+
+	switch strings.ToLower(pc.Parity) {
+	case "", "n", ParityNone:
+		pc.Parity = ParityNone
+	case "o", ParityOdd:
+		pc.Parity = ParityOdd
+	case "e", ParityEven:
+		pc.Parity = ParityEven
+	case "m", ParityMark:
+		pc.Parity = ParityMark
+	case "s", ParitySpace:
+		pc.Parity = ParitySpace
+	default:
+		return fmt.Errorf("invalid parity %q. Must be one of none, odd, even, mark, space", pc.Parity)
+	}
+
+	switch pc.StopBits {
+	case "":
+		pc.StopBits = "1"
+	case "1", "2":
+	case "1.5":
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("1.5 stop bits are only supported on Windows")
+		}
+	default:
+		return fmt.Errorf("invalid stop bits %q. Must be 1, 1.5 or 2", pc.StopBits)
+	}
+
+	switch strings.ToLower(pc.FlowControl) {
+	case "", FlowNone:
+		pc.FlowControl = FlowNone
+	case "hw", "hardware", "rts/cts", FlowRTSCTS:
+		pc.FlowControl = FlowRTSCTS
+	case "sw", "software", "xon/xoff", FlowXONXOFF:
+		pc.FlowControl = FlowXONXOFF
+	default:
+		return fmt.Errorf("invalid flow control %q. Must be one of none, rtscts, xonxoff", pc.FlowControl)
+	}
+
+	if pc.FlowControl == FlowRTSCTS && !pc.RTS {
+		return fmt.Errorf("RTS must be enabled for rtscts flow control")
+	}
+
+	if err := pc.Modbus.Normalize(); err != nil {
+		return fmt.Errorf("modbus: %w", err)
+	}
+	if pc.Modbus.Enabled() {
+		if pc.Poll.Enabled() {
+			return fmt.Errorf("modbus and poll cannot be used together")
+		}
+		// Ответы ведомых разбираются по карте регистров
+		pc.Framing = FramingConfig{Type: FramingModbus}
+		pc.Parser = ParserConfig{Type: ParserBinary, Layout: pc.Modbus.Layout()}
+	} else if pc.Framing.Type == FramingModbus {
+		return fmt.Errorf("modbus framing requires a register map")
+	}
+
+	if err := pc.SCPI.Normalize(); err != nil {
+		return fmt.Errorf("scpi: %w", err)
+	}
+	if pc.SCPI.Enabled() {
+		if pc.Poll.Enabled() || pc.Modbus.Enabled() {
+			return fmt.Errorf("scpi cannot be used together with poll or modbus")
+		}
+		// Ответы на запросы собираются в строку "имя=значение ..."
+		pc.Framing = FramingConfig{Type: FramingLine, MaxLength: pc.Framing.MaxLength}
+		pc.Parser = ParserConfig{Type: ParserKeyValue}
+	}
+
+	if err := pc.Parser.Normalize(); err != nil {
+		return fmt.Errorf("parser: %w", err)
+	}
+
+	if err := pc.normalizeEncoding(); err != nil {
+		return err
+	}
+
+	if err := pc.Checksum.Normalize(); err != nil {
+		return fmt.Errorf("checksum: %w", err)
+	}
+	if pc.Checksum.Enabled() && (pc.Modbus.Enabled() || pc.SCPI.Enabled() || pc.Parser.Type == ParserBinary) {
+		return fmt.Errorf("checksum only applies to text frames, not to modbus, scpi or binary records")
+	}
+
+	// A fixed-size record defaults to the size of its layout.
+	if pc.Framing.Type == FramingFixed && pc.Framing.Length == 0 {
+		pc.Framing.Length = LayoutSize(pc.Parser.Layout)
+	}
+
+	if err := pc.Framing.Normalize(); err != nil {
+		return fmt.Errorf("framing: %w", err)
+	}
+	// Байты 0x11 и 0x13 вырезаются из потока, а в двоичных кадрах это данные
+	if pc.FlowControl == FlowXONXOFF {
+		binary := pc.Parser.Type == ParserBinary || len(pc.Parser.Layout) > 0
+		if pc.Modbus.Enabled() || binary || (pc.Framing.Type != FramingLine && pc.Framing.Type != FramingDelimiter) {
+			return fmt.Errorf("xonxoff flow control removes the bytes 0x11 and 0x13 from the data, so it can only be used for text with line or delimiter framing")
+		}
+	}
+
+	if err := pc.DeviceTime.Normalize(); err != nil {
+		return fmt.Errorf("device time: %w", err)
+	}
+
+	if pc.Sequence.Modulus < 0 || pc.Sequence.Modulus == 1 {
+		return fmt.Errorf("sequence: invalid modulus %d", pc.Sequence.Modulus)
+	}
+
+	if err := pc.Capture.Normalize(); err != nil {
+		return fmt.Errorf("capture: %w", err)
+	}
+
+	if err := pc.Share.Normalize(); err != nil {
+		return fmt.Errorf("share: %w", err)
+	}
+
+	if err := pc.Reconnect.Normalize(); err != nil {
+		return fmt.Errorf("reconnect: %w", err)
+	}
+
+	if err := pc.Poll.Normalize(); err != nil {
+		return fmt.Errorf("poll: %w", err)
+	}
+
+	if err := pc.Watchdog.Normalize(); err != nil {
+		return fmt.Errorf("watchdog: %w", err)
+	}
+	if interval := pc.pollInterval(); pc.Watchdog.Enabled() && interval > 0 && pc.Watchdog.Timeout <= interval {
+		return fmt.Errorf("watchdog: timeout %s must be longer than the poll interval %s", pc.Watchdog.Timeout, interval)
+	}
+
+	return nil
+}
+
+func (pc *PollConfig) Normalize() error {
+	if !pc.Enabled() {
+		return nil
+	}
+	if pc.Interval < 0 || pc.Timeout < 0 {
+		return fmt.Errorf("interval and timeout must not be negative")
+	}
+	if pc.Interval == 0 {
+		pc.Interval = Duration(time.Second)
+	}
+	if pc.Timeout == 0 {
+		pc.Timeout = pc.Interval
+	}
+	if pc.Timeout > pc.Interval {
+		return fmt.Errorf("timeout %s is longer than the interval %s", pc.Timeout, pc.Interval)
+	}
+	return nil
+}
+
+func (rc *ReconnectConfig) Normalize() error {
+	if rc.InitialDelay <= 0 {
+		rc.InitialDelay = Duration(time.Second)
+	}
+	if rc.MaxDelay <= 0 {
+		rc.MaxDelay = Duration(time.Minute)
+	}
+	if rc.MaxDelay < rc.InitialDelay {
+		return fmt.Errorf("maximum delay %s is shorter than the initial delay %s", rc.MaxDelay, rc.InitialDelay)
+	}
+	if rc.Multiplier == 0 {
+		rc.Multiplier = 2
+	}
+	if rc.Multiplier < 1 {
+		return fmt.Errorf("invalid multiplier %g. Must be at least 1", rc.Multiplier)
+	}
+	if rc.Jitter < 0 || rc.Jitter >= 1 {
+		return fmt.Errorf("invalid jitter %g. Must be between 0 and 1", rc.Jitter)
+	}
+	if rc.MaxAttempts < 0 {
+		return fmt.Errorf("invalid maximum number of attempts %d", rc.MaxAttempts)
+	}
+	return nil
+}
+
+func (fc *FramingConfig) Normalize() error {
+	if fc.Type == "" {
+		fc.Type = FramingLine
+	}
+	if fc.MaxLength < 0 {
+		return fmt.Errorf("invalid maximum frame length %d", fc.MaxLength)
+	}
+	if fc.MaxLength == 0 {
+		fc.MaxLength = defaultMaxFrameLength
+	}
+
+	switch fc.Type {
+	case FramingLine, FramingSTXETX, FramingSLIP, FramingCOBS, FramingModbus:
+	case FramingDelimiter:
+		if fc.Delimiter == "" {
+			return fmt.Errorf("delimiter framing requires a delimiter")
+		}
+	case FramingFixed:
+		if fc.Length <= 0 {
+			return fmt.Errorf("fixed framing requires a positive frame length, got %d", fc.Length)
+		}
+	default:
+		return fmt.Errorf("unknown framing %q. Must be one of line, delimiter, fixed, stxetx, slip, cobs", fc.Type)
+	}
+
+	return nil
+}
+
+func (pc *ParserConfig) Normalize() error {
+	if pc.Type == "" {
+		pc.Type = ParserAuto
+	}
+	pc.Type = strings.ToLower(pc.Type)
+
+	if err := normalizeLayout(pc.Layout); err != nil {
+		return fmt.Errorf("layout: %w", err)
+	}
+
+	switch pc.Type {
+	case ParserBinary:
+		if len(pc.Layout) == 0 {
+			return fmt.Errorf("binary parser requires a layout")
+		}
+	case ParserRegex:
+		if pc.Pattern == "" {
+			return fmt.Errorf("regex parser requires a pattern")
+		}
+	}
+
+	return nil
+}
+
+// defaultPortName derives a name from the device path, e.g. "ttyUSB0"
+// for /dev/ttyUSB0.
+func defaultPortName(device string) string {
+	name := []rune(filepath.Base(device))
+	for i, r := range name {
+		if !validPortNameRune(r) {
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
+
+func validPortName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !validPortNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func validPortNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '.' || r == '_' || r == '-'
+}
+
+// LineSettings returns the settings in the usual short form, e.g. "8N1".
+func (pc PortConfig) LineSettings() string {
+	return fmt.Sprintf("%d%s%s", pc.DataBits, strings.ToUpper(pc.Parity[:1]), pc.StopBits)
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Character encodings of the text a device sends. Text frames are decoded
+// to UTF-8 before parsing; the raw frame is stored as it was received.
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingCP1251 = "cp1251"
+	EncodingCP866  = "cp866"
+	EncodingLatin1 = "latin1"
+)
+
+// normalizeEncoding checks the encoding of the port and accepts the usual
+// aliases. An empty encoding leaves the frames as they are.
+func (pc *PortConfig) normalizeEncoding() error {
+	switch strings.ToLower(pc.Encoding) {
+	case "":
+		pc.Encoding = ""
+		return nil
+	case EncodingUTF8, "utf8":
+		pc.Encoding = EncodingUTF8
+	case EncodingCP1251, "windows-1251", "win1251":
+		pc.Encoding = EncodingCP1251
+	case EncodingCP866, "ibm866", "dos866":
+		pc.Encoding = EncodingCP866
+	case EncodingLatin1, "latin-1", "iso-8859-1", "iso8859-1":
+		pc.Encoding = EncodingLatin1
+	default:
+		return fmt.Errorf("unknown encoding %q. Must be one of utf-8, cp1251, cp866, latin1", pc.Encoding)
+	}
+	// С раскладкой парсер auto тоже разбирает двоичные записи
+	if pc.Parser.Type == ParserBinary || len(pc.Parser.Layout) > 0 {
+		return fmt.Errorf("encoding only applies to text frames, not to modbus or binary records")
+	}
+	return nil
+}
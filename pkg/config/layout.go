@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LayoutField describes one field of a packed binary record. Fields follow
+// each other without gaps; use a "skipN" type to jump over N bytes.
+type LayoutField struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`   // int8 ... uint64, float32, float64, skipN
+	Endian string  `json:"endian"` // le (default) or be
+	Scale  float64 `json:"scale"`  // value = raw*scale + offset, 0 means 1
+	Offset float64 `json:"offset"`
+}
+
+const (
+	EndianLittle = "le"
+	EndianBig    = "be"
+)
+
+var layoutTypeSizes = map[string]int{
+	"int8":    1,
+	"uint8":   1,
+	"int16":   2,
+	"uint16":  2,
+	"int32":   4,
+	"uint32":  4,
+	"int64":   8,
+	"uint64":  8,
+	"float32": 4,
+	"float64": 8,
+}
+
+// Size returns the number of bytes the field occupies in a record.
+func (f LayoutField) Size() int {
+	if n, ok := skipSize(f.Type); ok {
+		return n
+	}
+	return layoutTypeSizes[f.Type]
+}
+
+// IsSkip reports whether the field is padding rather than a value.
+func (f LayoutField) IsSkip() bool {
+	_, ok := skipSize(f.Type)
+	return ok
+}
+
+func skipSize(typ string) (int, bool) {
+	if !strings.HasPrefix(typ, "skip") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(typ, "skip"))
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// LayoutSize returns the total record size of a layout in bytes.
+func LayoutSize(fields []LayoutField) int {
+	size := 0
+	for _, f := range fields {
+		size += f.Size()
+	}
+	return size
+}
+
+// ParseLayout parses the command line form of a layout: a comma separated
+// list of name:type[:endian[:scale[:offset]]], e.g.
+// "counter:uint16,temp:int32:le:0.01,_:skip2,pressure:float32:be".
+func ParseLayout(spec string) ([]LayoutField, error) {
+	var fields []LayoutField
+	for i, item := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) < 2 || len(parts) > 5 {
+			return nil, fmt.Errorf("field %d (%q): expected name:type[:endian[:scale[:offset]]]", i+1, item)
+		}
+
+		f := LayoutField{Name: parts[0], Type: parts[1]}
+		if len(parts) > 2 {
+			f.Endian = parts[2]
+		}
+		if len(parts) > 3 {
+			scale, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: invalid scale %q", f.Name, parts[3])
+			}
+			f.Scale = scale
+		}
+		if len(parts) > 4 {
+			offset, err := strconv.ParseFloat(parts[4], 64)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: invalid offset %q", f.Name, parts[4])
+			}
+			f.Offset = offset
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func normalizeLayout(fields []LayoutField) error {
+	names := make(map[string]bool)
+	for i := range fields {
+		f := &fields[i]
+		f.Type = strings.ToLower(f.Type)
+
+		if f.IsSkip() {
+			continue
+		}
+		if f.Size() == 0 {
+			return fmt.Errorf("field %q: unknown type %q", f.Name, f.Type)
+		}
+		if f.Name == "" {
+			return fmt.Errorf("field %d: name is empty", i+1)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate field name %q", f.Name)
+		}
+		names[f.Name] = true
+
+		switch strings.ToLower(f.Endian) {
+		case "", "little", EndianLittle:
+			f.Endian = EndianLittle
+		case "big", EndianBig:
+			f.Endian = EndianBig
+		default:
+			return fmt.Errorf("field %q: invalid endianness %q. Must be le or be", f.Name, f.Endian)
+		}
+		if f.Scale == 0 {
+			f.Scale = 1
+		}
+	}
+	return nil
+}
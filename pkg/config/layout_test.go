@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	fields, err := ParseLayout("counter:uint16, temp:int32:le:0.01,_:skip2,pressure:float32:be:2:-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LayoutField{
+		{Name: "counter", Type: "uint16"},
+		{Name: "temp", Type: "int32", Endian: "le", Scale: 0.01},
+		{Name: "_", Type: "skip2"},
+		{Name: "pressure", Type: "float32", Endian: "be", Scale: 2, Offset: -1},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("got %+v, want %+v", fields, want)
+	}
+	if size := LayoutSize(fields); size != 12 {
+		t.Fatalf("size %d, want 12", size)
+	}
+
+	if err := normalizeLayout(fields); err != nil {
+		t.Fatal(err)
+	}
+	if f := fields[0]; f.Endian != EndianLittle || f.Scale != 1 {
+		t.Fatalf("defaults not applied: %+v", f)
+	}
+	if !fields[2].IsSkip() || fields[3].IsSkip() {
+		t.Fatal("skip fields not recognized")
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		err  string
+	}{
+		{"counter", "expected name:type"},
+		{"a:int16:le:x", "invalid scale"},
+		{"a:int16:le:1:y", "invalid offset"},
+		{"a:int24", "unknown type"},
+		{"a:skip0", "unknown type"},
+		{"a:int16,a:uint8", "duplicate field name"},
+		{":int16", "name is empty"},
+		{"a:int16:middle", "invalid endianness"},
+	} {
+		fields, err := ParseLayout(tt.spec)
+		if err == nil {
+			err = normalizeLayout(fields)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got error %v, want one with %q", tt.spec, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ModbusConfig makes the port a Modbus RTU master that reads the listed
+// registers every Interval. Each slave must answer a request within
+// Timeout.
+type ModbusConfig struct {
+	Interval  Duration         `json:"interval"`
+	Timeout   Duration         `json:"timeout"`
+	Registers []ModbusRegister `json:"registers"`
+}
+
+// ModbusRegister maps one or more consecutive registers of a slave to a
+// channel. 32- and 64-bit types span two and four registers.
+type ModbusRegister struct {
+	Name      string  `json:"name"`
+	Slave     int     `json:"slave"`
+	Table     string  `json:"table"`      // holding (default) or input
+	Address   int     `json:"address"`    // zero-based register address
+	Type      string  `json:"type"`       // uint16 (default), int16, uint32, int32, float32, ...
+	WordOrder string  `json:"word_order"` // big (default): high word first; little: low word first
+	Scale     float64 `json:"scale"`      // value = raw*scale + offset, 0 means 1
+	Offset    float64 `json:"offset"`
+}
+
+const (
+	ModbusHolding = "holding"
+	ModbusInput   = "input"
+
+	WordOrderBig    = "big"
+	WordOrderLittle = "little"
+)
+
+func (mc ModbusConfig) Enabled() bool {
+	return len(mc.Registers) > 0
+}
+
+// Words returns the number of 16-bit registers the value occupies.
+func (r ModbusRegister) Words() int {
+	return layoutTypeSizes[r.Type] / 2
+}
+
+func (mc *ModbusConfig) Normalize() error {
+	if !mc.Enabled() {
+		return nil
+	}
+	if mc.Interval < 0 || mc.Timeout < 0 {
+		return fmt.Errorf("interval and timeout must not be negative")
+	}
+	if mc.Interval == 0 {
+		mc.Interval = Duration(time.Second)
+	}
+	if mc.Timeout == 0 {
+		mc.Timeout = Duration(500 * time.Millisecond)
+	}
+
+	names := make(map[string]bool)
+	for i := range mc.Registers {
+		r := &mc.Registers[i]
+		if r.Name == "" {
+			return fmt.Errorf("register %d has no name", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("register name %q is used more than once", r.Name)
+		}
+		names[r.Name] = true
+
+		if r.Slave < 1 || r.Slave > 247 {
+			return fmt.Errorf("register %q: invalid slave address %d. Must be between 1 and 247", r.Name, r.Slave)
+		}
+		switch strings.ToLower(r.Table) {
+		case "", ModbusHolding:
+			r.Table = ModbusHolding
+		case ModbusInput:
+			r.Table = ModbusInput
+		default:
+			return fmt.Errorf("register %q: invalid table %q. Must be holding or input", r.Name, r.Table)
+		}
+		if r.Type == "" {
+			r.Type = "uint16"
+		}
+		if size := layoutTypeSizes[r.Type]; size < 2 {
+			return fmt.Errorf("register %q: unsupported type %q", r.Name, r.Type)
+		}
+		if r.Address < 0 || r.Address+r.Words() > 0x10000 {
+			return fmt.Errorf("register %q: invalid address %d", r.Name, r.Address)
+		}
+		switch strings.ToLower(r.WordOrder) {
+		case "", WordOrderBig:
+			r.WordOrder = WordOrderBig
+		case WordOrderLittle:
+			r.WordOrder = WordOrderLittle
+		default:
+			return fmt.Errorf("register %q: invalid word order %q. Must be big or little", r.Name, r.WordOrder)
+		}
+	}
+	return nil
+}
+
+// Layout returns the record the Modbus master assembles from a poll: the
+// registers in configuration order, each value big-endian.
+func (mc ModbusConfig) Layout() []LayoutField {
+	fields := make([]LayoutField, len(mc.Registers))
+	for i, r := range mc.Registers {
+		fields[i] = LayoutField{
+			Name:   r.Name,
+			Type:   r.Type,
+			Endian: EndianBig,
+			Scale:  r.Scale,
+			Offset: r.Offset,
+		}
+	}
+	return fields
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Data sources of a port: a serial device, or a serial-to-Ethernet
+// converter reached as tcp://host:port (raw TCP) or rfc2217://host:port
+// (telnet with COM port control).
+const (
+	SourceSerial  = "serial"
+	SourceTCP     = "tcp"
+	SourceRFC2217 = "rfc2217"
+)
+
+// NetworkAddress splits a device such as "tcp://10.0.0.5:4001" into the
+// source and the host:port address. It reports false for device paths.
+func NetworkAddress(device string) (source, addr string, ok bool) {
+	for _, s := range []string{SourceTCP, SourceRFC2217} {
+		if rest, found := strings.CutPrefix(device, s+"://"); found {
+			return s, strings.TrimSuffix(rest, "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// normalizeNetwork picks the source of a port from its device and checks
+// the address of a network source.
+func (pc *PortConfig) normalizeNetwork() error {
+	source, addr, ok := NetworkAddress(pc.Device)
+	if !ok {
+		if pc.Source == SourceTCP || pc.Source == SourceRFC2217 {
+			return fmt.Errorf("source %s needs a device like %s://host:port", pc.Source, pc.Source)
+		}
+		return nil
+	}
+
+	if pc.Source != SourceSerial && pc.Source != source {
+		return fmt.Errorf("device %s does not match source %s", pc.Device, pc.Source)
+	}
+	pc.Source = source
+	if !pc.Match.IsEmpty() {
+		return fmt.Errorf("a network port must not have a USB match")
+	}
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return fmt.Errorf("invalid network address %q, expected host:port", addr)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ReplayConfig feeds the port from capture files instead of a device. File
+// may be a glob pattern; the matching files are replayed in name order,
+// which for capture files is the order they were written. At Speed 1 the
+// bytes arrive with their original timing, at 2 twice as fast; Fast
+// replays them as fast as they can be processed.
+type ReplayConfig struct {
+	File  string  `json:"file"`
+	Speed float64 `json:"speed"`
+	Fast  bool    `json:"fast"`
+}
+
+func (rc ReplayConfig) Enabled() bool {
+	return rc.File != ""
+}
+
+func (rc *ReplayConfig) Normalize() error {
+	if !rc.Enabled() {
+		return nil
+	}
+	if rc.Speed < 0 {
+		return fmt.Errorf("invalid speed %g", rc.Speed)
+	}
+	if rc.Speed == 0 {
+		rc.Speed = 1
+	}
+	return nil
+}
+
+// replayPortName names a replayed port after its files: "exp3-sensor-*.cap"
+// gives "replay-exp3-sensor".
+func replayPortName(pattern string) string {
+	name := filepath.Base(pattern)
+	if i := strings.IndexAny(name, "*?["); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".cap")
+	name = strings.TrimRight(name, "-_.")
+	if name == "" {
+		return "replay"
+	}
+	return "replay-" + name
+}
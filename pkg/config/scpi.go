@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SCPIConfig drives a bench instrument over SCPI. After every connect the
+// instrument is identified with *IDN? and the Init commands are sent; then
+// every Interval each of the Queries is sent and must be answered within
+// Timeout. Unless ErrorCheck is false, the error queue is read with
+// SYST:ERR? after the init sequence and after every round of queries.
+type SCPIConfig struct {
+	Init       []string    `json:"init"`
+	Queries    []SCPIQuery `json:"queries"`
+	Interval   Duration    `json:"interval"`
+	Timeout    Duration    `json:"timeout"`
+	ErrorCheck *bool       `json:"error_check"`
+}
+
+// SCPIQuery is a measurement query; its answer is stored as the channel
+// Name. An answer with several comma-separated values yields the channels
+// Name.1, Name.2 and so on.
+type SCPIQuery struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+func (sc SCPIConfig) Enabled() bool {
+	return len(sc.Queries) > 0
+}
+
+// ChecksErrors reports whether the error queue is read.
+func (sc SCPIConfig) ChecksErrors() bool {
+	return sc.ErrorCheck == nil || *sc.ErrorCheck
+}
+
+func (sc *SCPIConfig) Normalize() error {
+	if !sc.Enabled() {
+		if len(sc.Init) > 0 {
+			return fmt.Errorf("init commands require at least one query")
+		}
+		return nil
+	}
+	if sc.Interval < 0 || sc.Timeout < 0 {
+		return fmt.Errorf("interval and timeout must not be negative")
+	}
+	if sc.Interval == 0 {
+		sc.Interval = Duration(time.Second)
+	}
+	if sc.Timeout == 0 {
+		sc.Timeout = Duration(time.Second)
+	}
+
+	names := make(map[string]bool)
+	for i := range sc.Queries {
+		q := &sc.Queries[i]
+		q.Query = strings.TrimSpace(q.Query)
+		if q.Query == "" {
+			return fmt.Errorf("query %d is empty", i+1)
+		}
+		if !strings.Contains(q.Query, "?") {
+			return fmt.Errorf("%q is not a query", q.Query)
+		}
+		if q.Name == "" {
+			return fmt.Errorf("query %q has no name", q.Query)
+		}
+		if strings.ContainsAny(q.Name, " =:;,\t") {
+			return fmt.Errorf("query name %q must not contain spaces or separators", q.Name)
+		}
+		if names[q.Name] {
+			return fmt.Errorf("query name %q is used more than once", q.Name)
+		}
+		names[q.Name] = true
+	}
+	for i, cmd := range sc.Init {
+		sc.Init[i] = strings.TrimSpace(cmd)
+		if sc.Init[i] == "" {
+			return fmt.Errorf("init command %d is empty", i+1)
+		}
+	}
+	return nil
+}
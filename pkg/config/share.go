@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ShareConfig makes the port serve the bytes it reads to TCP clients on
+// Listen, so that other tools can watch the instrument while it is being
+// logged. In the read-only mode what clients send is discarded; in the
+// exclusive mode the client that connected first may write to the device.
+type ShareConfig struct {
+	Listen     string `json:"listen"` // host:port or :port
+	Mode       string `json:"mode"`   // read-only, exclusive
+	MaxClients int    `json:"max_clients"`
+}
+
+const (
+	ShareReadOnly  = "read-only"
+	ShareExclusive = "exclusive"
+)
+
+const defaultShareMaxClients = 8
+
+func (sc ShareConfig) Enabled() bool {
+	return sc.Listen != ""
+}
+
+func (sc *ShareConfig) Normalize() error {
+	if !sc.Enabled() {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(sc.Listen); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", sc.Listen, err)
+	}
+	sc.Mode = strings.ToLower(sc.Mode)
+	switch sc.Mode {
+	case "", "readonly", "ro":
+		sc.Mode = ShareReadOnly
+	case ShareReadOnly, ShareExclusive:
+	default:
+		return fmt.Errorf("unknown mode %q. Must be one of read-only, exclusive", sc.Mode)
+	}
+	if sc.MaxClients < 0 {
+		return fmt.Errorf("invalid number of clients %d", sc.MaxClients)
+	}
+	if sc.MaxClients == 0 {
+		sc.MaxClients = defaultShareMaxClients
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SimulatorConfig describes the synthetic device of the simulate mode. It
+// writes a record of all Waves every Interval in Format. Noise is the
+// standard deviation of the gaussian noise added to every wave. Garbage is
+// the probability that a record is preceded by random bytes. Every
+// DisconnectEvery the device is unplugged for Downtime. Counter names a
+// channel with a rolling record counter that wraps at CounterModulus.
+type SimulatorConfig struct {
+	Link            string   `json:"link"` // symlink to the pty, so that the path stays the same
+	Format          string   `json:"format"`
+	Interval        Duration `json:"interval"`
+	Waves           []Wave   `json:"waves"`
+	Noise           float64  `json:"noise"`
+	Garbage         float64  `json:"garbage"`
+	DisconnectEvery Duration `json:"disconnect_every"`
+	Downtime        Duration `json:"downtime"`
+	Counter         string   `json:"counter"`
+	CounterModulus  int64    `json:"counter_modulus"`
+	Seed            int64    `json:"seed"`
+}
+
+// Wave is a channel of the simulator: Offset + Amplitude * shape(t/Period).
+type Wave struct {
+	Name      string   `json:"name"`
+	Shape     string   `json:"shape"` // sine, square, triangle, sawtooth, constant
+	Offset    float64  `json:"offset"`
+	Amplitude float64  `json:"amplitude"`
+	Period    Duration `json:"period"`
+}
+
+const (
+	SimulateCSV  = "csv"
+	SimulateJSON = "json"
+	SimulateKV   = "kv"
+)
+
+const (
+	WaveSine     = "sine"
+	WaveSquare   = "square"
+	WaveTriangle = "triangle"
+	WaveSawtooth = "sawtooth"
+	WaveConstant = "constant"
+)
+
+const defaultWavePeriod = Duration(time.Minute)
+
+func (sc *SimulatorConfig) Normalize() error {
+	sc.Format = strings.ToLower(sc.Format)
+	switch sc.Format {
+	case "":
+		sc.Format = SimulateCSV
+	case SimulateCSV, SimulateJSON, SimulateKV:
+	default:
+		return fmt.Errorf("unknown format %q. Must be one of csv, json, kv", sc.Format)
+	}
+	if sc.Interval <= 0 {
+		return fmt.Errorf("invalid interval %s", sc.Interval)
+	}
+	if sc.Noise < 0 {
+		return fmt.Errorf("invalid noise %g", sc.Noise)
+	}
+	if sc.Garbage < 0 || sc.Garbage > 1 {
+		return fmt.Errorf("invalid garbage probability %g. Must be between 0 and 1", sc.Garbage)
+	}
+	if sc.DisconnectEvery < 0 || sc.Downtime < 0 {
+		return fmt.Errorf("invalid disconnect timing")
+	}
+	if sc.DisconnectEvery > 0 && sc.Downtime == 0 {
+		sc.Downtime = Duration(time.Second)
+	}
+	if sc.CounterModulus < 0 {
+		return fmt.Errorf("invalid counter modulus %d", sc.CounterModulus)
+	}
+
+	if len(sc.Waves) == 0 {
+		sc.Waves = []Wave{{Name: "value", Shape: WaveSine, Amplitude: 1}}
+	}
+	names := make(map[string]bool)
+	if sc.Counter != "" {
+		names[sc.Counter] = true
+	}
+	for i := range sc.Waves {
+		w := &sc.Waves[i]
+		w.Shape = strings.ToLower(w.Shape)
+		switch w.Shape {
+		case WaveSine, WaveSquare, WaveTriangle, WaveSawtooth, WaveConstant:
+		default:
+			return fmt.Errorf("wave %q: unknown shape %q. Must be one of sine, square, triangle, sawtooth, constant", w.Name, w.Shape)
+		}
+		if w.Name == "" || strings.ContainsAny(w.Name, ",=:\" \t") {
+			return fmt.Errorf("invalid wave name %q", w.Name)
+		}
+		if names[w.Name] {
+			return fmt.Errorf("duplicate channel %q", w.Name)
+		}
+		names[w.Name] = true
+		if w.Period <= 0 {
+			w.Period = defaultWavePeriod
+		}
+	}
+	return nil
+}
+
+// ParseWaves parses a list of waves such as
+// "temp:sine:20:5:1m,pressure:sawtooth:1000:2:10s". Each wave is
+// name:shape[:offset[:amplitude[:period]]]; the amplitude defaults to 1 and
+// the period to a minute.
+func ParseWaves(spec string) ([]Wave, error) {
+	var waves []Wave
+	for i, item := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) < 2 || len(parts) > 5 {
+			return nil, fmt.Errorf("wave %d (%q): expected name:shape[:offset[:amplitude[:period]]]", i+1, item)
+		}
+
+		w := Wave{Name: parts[0], Shape: parts[1], Amplitude: 1}
+		if len(parts) > 2 {
+			offset, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("wave %q: invalid offset %q", w.Name, parts[2])
+			}
+			w.Offset = offset
+		}
+		if len(parts) > 3 {
+			amplitude, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("wave %q: invalid amplitude %q", w.Name, parts[3])
+			}
+			w.Amplitude = amplitude
+		}
+		if len(parts) > 4 {
+			period, err := time.ParseDuration(parts[4])
+			if err != nil {
+				return nil, fmt.Errorf("wave %q: invalid period %q", w.Name, parts[4])
+			}
+			w.Period = Duration(period)
+		}
+		waves = append(waves, w)
+	}
+	return waves, nil
+}
+
+// LoadSimulator parses the options of the simulate mode.
+func LoadSimulator(name string, args []string) (*SimulatorConfig, error) {
+	sc := &SimulatorConfig{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&sc.Link, "link", "", "Create a symlink to the pseudo-terminal at this path, e.g. /tmp/ttySIM")
+	fs.StringVar(&sc.Format, "format", SimulateCSV, "Record format (csv, json, kv)")
+	fs.DurationVar((*time.Duration)(&sc.Interval), "interval", time.Second, "Interval between records")
+	waves := fs.String("waves", "value:sine", "Channels, e.g. \"temp:sine:20:5:1m,pressure:sawtooth:1000:2:10s\" (name:shape[:offset[:amplitude[:period]]])")
+	fs.Float64Var(&sc.Noise, "noise", 0, "Standard deviation of the gaussian noise added to every channel")
+	fs.Float64Var(&sc.Garbage, "garbage", 0, "Probability that a record is preceded by random bytes")
+	fs.DurationVar((*time.Duration)(&sc.DisconnectEvery), "disconnect-every", 0, "Unplug the device this often, 0 never")
+	fs.DurationVar((*time.Duration)(&sc.Downtime), "downtime", time.Second, "How long the device stays unplugged")
+	fs.StringVar(&sc.Counter, "counter", "", "Add a rolling record counter channel with this name")
+	fs.Int64Var(&sc.CounterModulus, "counter-modulus", 0, "The counter wraps to 0 at this value, 0 means it never wraps")
+	fs.Int64Var(&sc.Seed, "seed", 0, "Seed of the noise and garbage, 0 picks one from the clock")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [options]\n", name)
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	w, err := ParseWaves(*waves)
+	if err != nil {
+		return nil, fmt.Errorf("invalid waves: %w", err)
+	}
+	sc.Waves = w
+	if err := sc.Normalize(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// WatchdogConfig raises an alert when the port is open but gets no frame
+// for Timeout: a hung sensor keeps its port open and just stops talking.
+// The watchdog can then pulse DTR, which resets many devices, and send
+// WakeCommand with the command ending.
+type WatchdogConfig struct {
+	Timeout     Duration `json:"timeout"`
+	PulseDTR    bool     `json:"pulse_dtr"`
+	WakeCommand string   `json:"wake_command"`
+}
+
+func (wc WatchdogConfig) Enabled() bool {
+	return wc.Timeout > 0
+}
+
+func (wc *WatchdogConfig) Normalize() error {
+	if wc.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if !wc.Enabled() {
+		if wc.PulseDTR || wc.WakeCommand != "" {
+			return fmt.Errorf("DTR pulse and wake command need a timeout")
+		}
+		return nil
+	}
+	if wc.Timeout < Duration(time.Second) {
+		return fmt.Errorf("timeout %s is too short", wc.Timeout)
+	}
+	return nil
+}
+
+// pollInterval returns how often a polled port queries its device: by
+// plain polling, Modbus or SCPI. It is 0 for a device that talks on its
+// own.
+func (pc PortConfig) pollInterval() Duration {
+	switch {
+	case pc.Modbus.Enabled():
+		return pc.Modbus.Interval
+	case pc.SCPI.Enabled():
+		return pc.SCPI.Interval
+	case pc.Poll.Enabled():
+		return pc.Poll.Interval
+	}
+	return 0
+}